@@ -2,7 +2,6 @@ package goel
 
 import (
 	"context"
-	"github.com/pkg/errors"
 	"go/ast"
 	"go/token"
 	"reflect"
@@ -15,10 +14,12 @@ type binaryCompiledExpression struct {
 	right      CompiledExpression
 	operate    func(l, r interface{}) interface{}
 	lpos, rpos token.Pos
+	op         token.Token
+	opPos      token.Pos
 }
 
 func newBinaryCompiledExpression(rt reflect.Type, left CompiledExpression, right CompiledExpression, exp *ast.BinaryExpr, op func(l, r interface{}) interface{}) *binaryCompiledExpression {
-	return &binaryCompiledExpression{nopExpression{exp}, rt, left, right, op, exp.X.Pos(), exp.Y.Pos()}
+	return &binaryCompiledExpression{nopExpression{exp}, rt, left, right, op, exp.X.Pos(), exp.Y.Pos(), exp.Op, exp.OpPos}
 }
 
 func addint(l, r interface{}) interface{} {
@@ -125,18 +126,32 @@ func neq(l, r interface{}) interface{} {
 	return l != r
 }
 
-func (bce *binaryCompiledExpression) Execute(ectx context.Context) (interface{}, error) {
+func (bce *binaryCompiledExpression) Execute(ectx context.Context) (result interface{}, err error) {
+	defer recoverRuntimePanic(bce.opPos, &err)
+	if err := checkpoint(ectx, bce.opPos); err != nil {
+		return nil, err
+	}
 	l, err := bce.left.Execute(ectx)
 	if err != nil {
 		return nil, err
 	}
+	if lb, ok := l.(bool); ok && (bce.op == token.LAND && !lb || bce.op == token.LOR && lb) {
+		return lb, nil
+	}
 	r, err := bce.right.Execute(ectx)
 	if err != nil {
 		return nil, err
 	}
 	lt, _ := bce.left.ReturnType()
-	if !reflect.TypeOf(r).AssignableTo(lt) {
-		return nil, errors.Errorf("type mismatch expected %s but found %T", bce.returnType.Name(), r)
+	if r != nil && !reflect.TypeOf(r).AssignableTo(lt) {
+		return nil, runtimeErrorf(bce.rpos, TypeMismatch, "type mismatch expected %s but found %T", lt.Name(), r)
+	}
+	if ls, ok := l.(string); ok && bce.op == token.ADD {
+		// The concatenation is charged before it allocates so that a single one can not overrun the budget.
+		rs, _ := r.(string)
+		if err := chargeBytes(ectx, bce.opPos, len(ls)+len(rs)); err != nil {
+			return nil, err
+		}
 	}
 	return bce.operate(l, r), nil
 }
@@ -157,8 +172,14 @@ func evalAddBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compile
 		return newBinaryCompiledExpression(lt, left, right, exp, addint)
 	case lt.AssignableTo(DoubleType):
 		return newBinaryCompiledExpression(lt, left, right, exp, addfloat)
+	case lt.AssignableTo(Int64Type):
+		return newBinaryCompiledExpression(lt, left, right, exp, addint64)
+	case lt.AssignableTo(Uint64Type):
+		return newBinaryCompiledExpression(lt, left, right, exp, adduint64)
+	case lt.AssignableTo(ComplexType):
+		return newBinaryCompiledExpression(lt, left, right, exp, addcomplex)
 	default:
-		return newErrorExpression(errors.Errorf("%d: unsupported type %s", exp.X.Pos(), lt.Name()))
+		return newErrorExpression(compileErrorf(exp.X.Pos(), UnsupportedExpression, "unsupported type %s", lt.Name()))
 	}
 }
 
@@ -168,8 +189,14 @@ func evalSubBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compile
 		return newBinaryCompiledExpression(lt, left, right, exp, subint)
 	case lt.AssignableTo(DoubleType):
 		return newBinaryCompiledExpression(lt, left, right, exp, subfloat)
+	case lt.AssignableTo(Int64Type):
+		return newBinaryCompiledExpression(lt, left, right, exp, subint64)
+	case lt.AssignableTo(Uint64Type):
+		return newBinaryCompiledExpression(lt, left, right, exp, subuint64)
+	case lt.AssignableTo(ComplexType):
+		return newBinaryCompiledExpression(lt, left, right, exp, subcomplex)
 	default:
-		return newErrorExpression(errors.Errorf("%d: unsupported type %s", exp.X.Pos(), lt.Name()))
+		return newErrorExpression(compileErrorf(exp.X.Pos(), UnsupportedExpression, "unsupported type %s", lt.Name()))
 	}
 }
 
@@ -179,8 +206,14 @@ func evalMulBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compile
 		return newBinaryCompiledExpression(lt, left, right, exp, mulint)
 	case lt.AssignableTo(DoubleType):
 		return newBinaryCompiledExpression(lt, left, right, exp, mulfloat)
+	case lt.AssignableTo(Int64Type):
+		return newBinaryCompiledExpression(lt, left, right, exp, mulint64)
+	case lt.AssignableTo(Uint64Type):
+		return newBinaryCompiledExpression(lt, left, right, exp, muluint64)
+	case lt.AssignableTo(ComplexType):
+		return newBinaryCompiledExpression(lt, left, right, exp, mulcomplex)
 	default:
-		return newErrorExpression(errors.Errorf("%d: unsupported type %s", exp.X.Pos(), lt.Name()))
+		return newErrorExpression(compileErrorf(exp.X.Pos(), UnsupportedExpression, "unsupported type %s", lt.Name()))
 	}
 }
 
@@ -190,8 +223,14 @@ func evalQuoBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compile
 		return newBinaryCompiledExpression(lt, left, right, exp, divint)
 	case lt.AssignableTo(DoubleType):
 		return newBinaryCompiledExpression(lt, left, right, exp, divfloat)
+	case lt.AssignableTo(Int64Type):
+		return newBinaryCompiledExpression(lt, left, right, exp, divint64)
+	case lt.AssignableTo(Uint64Type):
+		return newBinaryCompiledExpression(lt, left, right, exp, divuint64)
+	case lt.AssignableTo(ComplexType):
+		return newBinaryCompiledExpression(lt, left, right, exp, divcomplex)
 	default:
-		return newErrorExpression(errors.Errorf("%d: unsupported type %s", exp.X.Pos(), lt.Name()))
+		return newErrorExpression(compileErrorf(exp.X.Pos(), UnsupportedExpression, "unsupported type %s", lt.Name()))
 	}
 }
 
@@ -199,14 +238,14 @@ func evalLAndBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compil
 	if lt.AssignableTo(BoolType) {
 		return newBinaryCompiledExpression(BoolType, left, right, exp, and)
 	}
-	return newErrorExpression(errors.Errorf("%d: unsupported type %s", exp.X.Pos(), lt.Name()))
+	return newErrorExpression(compileErrorf(exp.X.Pos(), UnsupportedExpression, "unsupported type %s", lt.Name()))
 }
 
 func evalLOrBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compiledExpression) compiledExpression {
 	if lt.AssignableTo(BoolType) {
 		return newBinaryCompiledExpression(BoolType, left, right, exp, or)
 	}
-	return newErrorExpression(errors.Errorf("%d: unsupported type %s", exp.X.Pos(), lt.Name()))
+	return newErrorExpression(compileErrorf(exp.X.Pos(), UnsupportedExpression, "unsupported type %s", lt.Name()))
 }
 
 func evalGtrBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compiledExpression) compiledExpression {
@@ -216,8 +255,12 @@ func evalGtrBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compile
 		return newBinaryCompiledExpression(BoolType, left, right, exp, gtrfloat)
 	} else if lt.AssignableTo(StringType) {
 		return newBinaryCompiledExpression(BoolType, left, right, exp, gtrstring)
+	} else if lt.AssignableTo(Int64Type) {
+		return newBinaryCompiledExpression(BoolType, left, right, exp, gtrint64)
+	} else if lt.AssignableTo(Uint64Type) {
+		return newBinaryCompiledExpression(BoolType, left, right, exp, gtruint64)
 	}
-	return newErrorExpression(errors.Errorf("%d: unsupported type %s", exp.X.Pos(), lt.Name()))
+	return newErrorExpression(compileErrorf(exp.X.Pos(), UnsupportedExpression, "unsupported type %s", lt.Name()))
 }
 
 func evalGEqBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compiledExpression) compiledExpression {
@@ -227,8 +270,12 @@ func evalGEqBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compile
 		return newBinaryCompiledExpression(BoolType, left, right, exp, geqfloat)
 	} else if lt.AssignableTo(StringType) {
 		return newBinaryCompiledExpression(BoolType, left, right, exp, geqstring)
+	} else if lt.AssignableTo(Int64Type) {
+		return newBinaryCompiledExpression(BoolType, left, right, exp, geqint64)
+	} else if lt.AssignableTo(Uint64Type) {
+		return newBinaryCompiledExpression(BoolType, left, right, exp, gequint64)
 	}
-	return newErrorExpression(errors.Errorf("%d: unsupported type %s", exp.X.Pos(), lt.Name()))
+	return newErrorExpression(compileErrorf(exp.X.Pos(), UnsupportedExpression, "unsupported type %s", lt.Name()))
 }
 
 func evalLssBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compiledExpression) compiledExpression {
@@ -238,8 +285,12 @@ func evalLssBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compile
 		return newBinaryCompiledExpression(BoolType, left, right, exp, lssfloat)
 	} else if lt.AssignableTo(StringType) {
 		return newBinaryCompiledExpression(BoolType, left, right, exp, lssstring)
+	} else if lt.AssignableTo(Int64Type) {
+		return newBinaryCompiledExpression(BoolType, left, right, exp, lssint64)
+	} else if lt.AssignableTo(Uint64Type) {
+		return newBinaryCompiledExpression(BoolType, left, right, exp, lssuint64)
 	}
-	return newErrorExpression(errors.Errorf("%d: unsupported type %s", exp.X.Pos(), lt.Name()))
+	return newErrorExpression(compileErrorf(exp.X.Pos(), UnsupportedExpression, "unsupported type %s", lt.Name()))
 }
 
 func evalLEqBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compiledExpression) compiledExpression {
@@ -249,33 +300,50 @@ func evalLEqBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compile
 		return newBinaryCompiledExpression(BoolType, left, right, exp, leqfloat)
 	} else if lt.AssignableTo(StringType) {
 		return newBinaryCompiledExpression(BoolType, left, right, exp, leqstring)
+	} else if lt.AssignableTo(Int64Type) {
+		return newBinaryCompiledExpression(BoolType, left, right, exp, leqint64)
+	} else if lt.AssignableTo(Uint64Type) {
+		return newBinaryCompiledExpression(BoolType, left, right, exp, lequint64)
 	}
-	return newErrorExpression(errors.Errorf("%d: unsupported type %s", exp.X.Pos(), lt.Name()))
+	return newErrorExpression(compileErrorf(exp.X.Pos(), UnsupportedExpression, "unsupported type %s", lt.Name()))
 }
 
 func evalRemBinaryExpr(exp *ast.BinaryExpr, lt reflect.Type, left, right compiledExpression) compiledExpression {
 	if lt.AssignableTo(IntType) {
 		return newBinaryCompiledExpression(IntType, left, right, exp, modint)
+	} else if lt.AssignableTo(Int64Type) {
+		return newBinaryCompiledExpression(Int64Type, left, right, exp, modint64)
+	} else if lt.AssignableTo(Uint64Type) {
+		return newBinaryCompiledExpression(Uint64Type, left, right, exp, moduint64)
 	}
-	return newErrorExpression(errors.Errorf("%d: unsupported type %s", exp.X.Pos(), lt.Name()))
+	return newErrorExpression(compileErrorf(exp.X.Pos(), UnsupportedExpression, "unsupported type %s", lt.Name()))
 }
 
 func evalBinaryExpr(pctx context.Context, exp *ast.BinaryExpr) compiledExpression {
 	left := compile(pctx, exp.X)
-	if left.Error() != nil {
-		return left
-	}
-	lt, _ := left.ReturnType()
 	right := compile(pctx, exp.Y)
-	if right.Error() != nil {
-		return right
+	if failed := collectErrors(pctx, left, right); failed != nil {
+		return failed
 	}
+	lt, _ := left.ReturnType()
 	rt, _ := right.ReturnType()
+	if compileOptionsFrom(pctx).lenientNumerics {
+		if pt := promotedNumericType(lt, rt); pt != nil {
+			left, right = convert(left, exp.X.Pos(), pt), convert(right, exp.Y.Pos(), pt)
+			lt, rt = pt, pt
+		}
+	}
 	if !lt.AssignableTo(rt) {
-		return newErrorExpression(errors.Errorf("%d: type mismatch in binary expression", exp.OpPos))
+		return newErrorExpression(compileErrorf(exp.OpPos, TypeMismatch, "type mismatch in binary expression"))
+	}
+	if !(lt.AssignableTo(StringType) || lt.AssignableTo(IntType) || lt.AssignableTo(DoubleType) || lt.AssignableTo(BoolType) ||
+		lt.AssignableTo(Int64Type) || lt.AssignableTo(Uint64Type) || lt.AssignableTo(ComplexType)) {
+		return newErrorExpression(compileErrorf(exp.OpPos, UnsupportedExpression, "unsupported binary expression type: %s", lt.String()))
 	}
-	if !(lt.AssignableTo(StringType) || lt.AssignableTo(IntType) || lt.AssignableTo(DoubleType) || lt.AssignableTo(BoolType)) {
-		return newErrorExpression(errors.Errorf("%d: unsupported binary expression type: %s", exp.OpPos, lt.String()))
+	if compileOptionsFrom(pctx).checkedArithmetic {
+		if operate, ok := checkedOperators[exp.Op][lt]; ok {
+			return newBinaryCompiledExpression(lt, left, right, exp, operate)
+		}
 	}
 	switch exp.Op {
 	case token.ADD:
@@ -305,6 +373,6 @@ func evalBinaryExpr(pctx context.Context, exp *ast.BinaryExpr) compiledExpressio
 	case token.REM:
 		return evalRemBinaryExpr(exp, lt, left, right)
 	default:
-		return newErrorExpression(errors.Errorf("%d: unsupported binary operation %s", exp.OpPos, exp.Op))
+		return newErrorExpression(compileErrorf(exp.OpPos, UnsupportedExpression, "unsupported binary operation %s", exp.Op))
 	}
 }
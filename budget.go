@@ -0,0 +1,90 @@
+package goel
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+)
+
+// Budget limits the resources a single execution of an expression may use.  A limit of zero is no limit.  Use
+// WithBudget to attach a Budget to the execution context.
+type Budget struct {
+	// MaxSteps limits the number of operations, such as identifier lookups, operators, selectors, indexing, slicing
+	// and calls, that are evaluated.
+	MaxSteps int
+	// MaxCalls limits the number of function calls.
+	MaxCalls int
+	// MaxBytes limits the number of bytes produced by string concatenation and by slicing.
+	MaxBytes int
+}
+
+// BudgetLimit identifies one of the limits of a Budget.
+type BudgetLimit int
+
+const (
+	// StepLimit is the MaxSteps limit.
+	StepLimit BudgetLimit = iota + 1
+	// CallLimit is the MaxCalls limit.
+	CallLimit
+	// ByteLimit is the MaxBytes limit.
+	ByteLimit
+)
+
+var budgetLimitUnits = map[BudgetLimit]string{
+	StepLimit: "steps",
+	CallLimit: "function calls",
+	ByteLimit: "bytes",
+}
+
+// BudgetError is the cause of the *RuntimeError of kind BudgetExceeded returned by Execute when an execution exceeds
+// one of the limits of its Budget.
+type BudgetError struct {
+	// Limit is the limit that was exceeded.
+	Limit BudgetLimit
+	// Max is the value of the limit.
+	Max int
+}
+
+func (be *BudgetError) Error() string {
+	return fmt.Sprintf("budget exceeded: more than %d %s", be.Max, budgetLimitUnits[be.Limit])
+}
+
+// budgetExceeded returns the error reported at pos when the execution exceeds the limit of value max.
+func budgetExceeded(pos token.Pos, limit BudgetLimit, max int) error {
+	be := &BudgetError{limit, max}
+	return &RuntimeError{Kind: BudgetExceeded, Pos: pos, Msg: be.Error(), Err: be}
+}
+
+type budgetKey struct{}
+
+// WithBudget returns a copy of ctx carrying budget.  An expression executed with the returned context, or a context
+// derived from it, fails with a BudgetExceeded error as soon as it exceeds one of the limits of budget.
+func WithBudget(ctx context.Context, budget Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, budget)
+}
+
+// chargeCall counts a function call of the execution ectx belongs to against its budget.
+func chargeCall(ectx context.Context, pos token.Pos) error {
+	state := executionStateFrom(ectx)
+	if state == nil {
+		return nil
+	}
+	state.calls++
+	if state.budget.MaxCalls > 0 && state.calls > state.budget.MaxCalls {
+		return budgetExceeded(pos, CallLimit, state.budget.MaxCalls)
+	}
+	return nil
+}
+
+// chargeBytes counts n bytes about to be produced by the execution ectx belongs to against its budget.
+func chargeBytes(ectx context.Context, pos token.Pos, n int) error {
+	state := executionStateFrom(ectx)
+	if state == nil {
+		return nil
+	}
+	state.bytes += n
+	if state.budget.MaxBytes > 0 && state.bytes > state.budget.MaxBytes {
+		return budgetExceeded(pos, ByteLimit, state.budget.MaxBytes)
+	}
+	return nil
+}
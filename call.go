@@ -2,8 +2,8 @@ package goel
 
 import (
 	"context"
-	"github.com/pkg/errors"
 	"go/ast"
+	"go/types"
 	"reflect"
 )
 
@@ -13,7 +13,19 @@ type callCompiledExpression struct {
 	fnExp        compiledExpression
 	args         []compiledExpression
 	returnsError bool
+	withContext  bool
 	returnType   reflect.Type
+	// resultTypes are the types of the elements of the Tuple returned by a function with several results.
+	resultTypes []reflect.Type
+	// spread is set when the single argument is a Tuple whose elements are passed as the arguments.
+	spread      bool
+	name        string
+	panicPolicy PanicPolicy
+	panicHook   PanicHook
+	pure        *PureRegistry
+	onError     errorHandler
+	// policy is the policy of the Sandbox option applied to function values, which are not checked when compiling.
+	policy *Policy
 }
 
 func (cce *callCompiledExpression) ReturnType() (reflect.Type, error) {
@@ -21,22 +33,49 @@ func (cce *callCompiledExpression) ReturnType() (reflect.Type, error) {
 }
 
 func (cce *callCompiledExpression) Execute(ectx context.Context) (interface{}, error) {
+	if err := checkpoint(ectx, cce.exp.Lparen); err != nil {
+		return nil, err
+	}
 	_fn, err := cce.fnExp.Execute(ectx)
 	if err != nil {
 		return nil, err
 	}
 	fn := reflect.ValueOf(_fn)
 	if _fn == nil {
-		return nil, errors.Errorf("%d: function expression returned nil", cce.exp.Fun.Pos())
+		return nil, runtimeErrorf(cce.exp.Fun.Pos(), NilDereference, "function expression returned nil")
 	}
 	if !fn.IsValid() || fn.Kind() != reflect.Func || fn.IsNil() {
-		return nil, errors.Errorf("%d: not a function", cce.exp.Pos())
+		return nil, runtimeErrorf(cce.exp.Pos(), NotAFunction, "not a function")
+	}
+	var recv reflect.Type
+	if ident, ok := cce.exp.Fun.(*ast.Ident); ok && (cce.pure != nil || cce.policy != nil) {
+		recv = lookupMethodReceiver(ectx, ident.Name)
+	}
+	if cce.pure != nil {
+		if err := cce.pure.checkPureFunc(cce.exp.Lparen, cce.name, fn, recv); err != nil {
+			return nil, err
+		}
+	}
+	if err := cce.checkFuncPolicy(recv, fn); err != nil {
+		return nil, err
+	}
+	args, err := collectArgumentValues(ectx, fn, cce.args, cce.withContext, cce.spread)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCanceled(ectx, cce.exp.Lparen); err != nil {
+		return nil, err
+	}
+	if err := chargeCall(ectx, cce.exp.Lparen); err != nil {
+		return nil, err
 	}
-	args, err := collectArgumentValues(ectx, fn, cce.args)
+	results, err := cce.callFunction(ectx, fn, args)
 	if err != nil {
 		return nil, err
 	}
-	results := fn.Call(args)
+	if err := checkCanceled(ectx, cce.exp.Rparen); err != nil {
+		return nil, err
+	}
 	var outValues []reflect.Value
 	var errValue *reflect.Value
 	if cce.returnsError {
@@ -45,42 +84,83 @@ func (cce *callCompiledExpression) Execute(ectx context.Context) (interface{}, e
 	} else {
 		outValues = results
 	}
+	// A successful call of a function only returning an error evaluates to a nil error, as ReturnType says.
 	var out interface{}
 	if len(outValues) == 1 {
 		out = outValues[0].Interface()
-	} else {
+	} else if len(outValues) > 1 {
 		outs := make([]interface{}, 0, len(outValues))
 		for _, o := range outValues {
 			outs = append(outs, o.Interface())
 		}
-		out = outs
+		out = Tuple(outs)
 	}
 	if errValue != nil && errValue.CanInterface() && !errValue.IsNil() {
+		if cce.onError.policy != ErrorAbort {
+			return cce.errorResult(), nil
+		}
 		err = errValue.Interface().(error)
 	}
 	return out, err
 }
 
-func collectArgumentValues(ectx context.Context, fn reflect.Value, argExps []compiledExpression) ([]reflect.Value, error) {
-	args := make([]reflect.Value, 0, len(argExps))
+// collectArgumentValues executes the argument expressions of a call of fn.  If withContext is set, the execution
+// context is passed as the first argument.  If spread is set, the elements of the Tuple the single argument
+// expression evaluates to are passed as the arguments.
+func collectArgumentValues(ectx context.Context, fn reflect.Value, argExps []compiledExpression, withContext, spread bool) ([]reflect.Value, error) {
+	expectedNumberOfArgs := fn.Type().NumIn()
+	args := make([]reflect.Value, 0, len(argExps)+1)
+	if withContext {
+		args = append(args, reflect.ValueOf(ectx))
+	}
+	if spread {
+		return spreadArgumentValues(ectx, fn, argExps[0], args)
+	}
 	for _, argExp := range argExps {
 		v, err := argExp.Execute(ectx)
 		if err != nil {
 			return nil, err
 		}
+		if i := len(args); v == nil && i < expectedNumberOfArgs {
+			args = append(args, reflect.Zero(fn.Type().In(i)))
+			continue
+		}
 		argTyp, _ := argExp.ReturnType()
-		if !reflect.TypeOf(v).AssignableTo(argTyp) {
-			return nil, errors.Errorf("%d: type mismatch", argExp.Pos())
+		if v == nil || !reflect.TypeOf(v).AssignableTo(argTyp) {
+			return nil, runtimeErrorf(argExp.Pos(), TypeMismatch, "type mismatch")
 		}
 		args = append(args, reflect.ValueOf(v))
 	}
-	expectedNumberOfArgs := fn.Type().NumIn()
 	if expectedNumberOfArgs != len(args) {
 		howMany := "too few"
 		if expectedNumberOfArgs < len(args) {
 			howMany = "too many"
 		}
-		return nil, errors.Errorf("%d: %s arguments in call.  expected %d, found %d", argExps[0].Pos(), howMany, expectedNumberOfArgs, len(args))
+		return nil, runtimeErrorf(argExps[0].Pos(), Arity, "%s arguments in call.  expected %d, found %d", howMany, expectedNumberOfArgs, len(args))
+	}
+	return args, nil
+}
+
+// spreadArgumentValues appends the elements of the Tuple argExp evaluates to to args.
+func spreadArgumentValues(ectx context.Context, fn reflect.Value, argExp compiledExpression, args []reflect.Value) ([]reflect.Value, error) {
+	v, err := argExp.Execute(ectx)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := v.(Tuple)
+	if !ok || len(args)+len(t) != fn.Type().NumIn() {
+		return nil, runtimeErrorf(argExp.Pos(), TypeMismatch, "type mismatch")
+	}
+	for _, e := range t {
+		in := fn.Type().In(len(args))
+		if e == nil {
+			args = append(args, reflect.Zero(in))
+			continue
+		}
+		if !reflect.TypeOf(e).AssignableTo(in) {
+			return nil, runtimeErrorf(argExp.Pos(), TypeMismatch, "type mismatch")
+		}
+		args = append(args, reflect.ValueOf(e))
 	}
 	return args, nil
 }
@@ -90,66 +170,137 @@ func functionReturnsError(fnType reflect.Type) bool {
 	return returnsError
 }
 
-func functionArgs(pctx context.Context, isMember bool, fnType reflect.Type, exp *ast.CallExpr) ([]compiledExpression, error) {
+// acceptsContext reports whether the first parameter of fnType, if any, is a context.Context.
+func acceptsContext(fnType reflect.Type) bool {
+	return fnType.NumIn() > 0 && fnType.In(0) == contextType
+}
+
+// functionArgs compiles the arguments of a call of a function of type fnType and reports whether the elements of
+// the Tuple of a single argument are spread over the parameters.  A leading context.Context parameter, which is
+// passed the execution context, has no argument.
+func functionArgs(pctx context.Context, fnType reflect.Type, exp *ast.CallExpr) ([]compiledExpression, bool, error) {
 	expectedNumberofArgs := fnType.NumIn()
 	argOffset := 0
-	if isMember {
+	if acceptsContext(fnType) {
 		expectedNumberofArgs--
-		argOffset = 1
+		argOffset++
+	}
+	if len(exp.Args) == 1 && expectedNumberofArgs > 1 {
+		argExp := compile(pctx, exp.Args[0])
+		if resultTypes := tupleResultTypes(argExp); resultTypes != nil {
+			argExps, err := tupleArgs(fnType, argOffset, exp, argExp, resultTypes)
+			return argExps, true, err
+		}
 	}
 	if expectedNumberofArgs > len(exp.Args) {
-		return nil, errors.Errorf("%d: too few parameters to function call, expected %d, found %d", exp.Rparen, expectedNumberofArgs, len(exp.Args))
+		return nil, false, compileErrorf(exp.Rparen, Arity, "too few parameters to function call, expected %d, found %d", expectedNumberofArgs, len(exp.Args))
 	}
 	if expectedNumberofArgs < len(exp.Args) {
-		return nil, errors.Errorf("%d: too many parameters to function call, expected %d, found %d", exp.Rparen, expectedNumberofArgs, len(exp.Args))
+		return nil, false, compileErrorf(exp.Rparen, Arity, "too many parameters to function call, expected %d, found %d", expectedNumberofArgs, len(exp.Args))
 	}
 	argExps := make([]compiledExpression, 0, len(exp.Args))
 	for i, argExpr := range exp.Args {
 		argExp := compile(pctx, argExpr)
-		if argExp.Error() != nil {
-			return nil, argExp.Error()
-		}
-		argTyp, _ := argExp.ReturnType()
-		if !argTyp.AssignableTo(fnType.In(i + argOffset)) {
-			return nil, errors.Errorf("%d: type mismatch in argument %d", argExpr.Pos(), i)
+		if argExp.Error() == nil {
+			argTyp, _ := argExp.ReturnType()
+			if !argTyp.AssignableTo(fnType.In(i + argOffset)) {
+				argExp = newErrorExpression(compileErrorf(argExpr.Pos(), TypeMismatch, "type mismatch in argument %d", i))
+			}
 		}
 		argExps = append(argExps, argExp)
 	}
+	if failed := collectErrors(pctx, argExps...); failed != nil {
+		return nil, false, failed.Error()
+	}
 	if expectedNumberofArgs != len(argExps) {
 		panic("failed to build argFns array")
 	}
-	return argExps, nil
+	return argExps, false, nil
+}
+
+// tupleArgs verifies that the elements of the Tuple argExp evaluates to, whose types are resultTypes, match the
+// parameters of fnType from argOffset on.
+func tupleArgs(fnType reflect.Type, argOffset int, exp *ast.CallExpr, argExp compiledExpression, resultTypes []reflect.Type) ([]compiledExpression, error) {
+	expectedNumberofArgs := fnType.NumIn() - argOffset
+	if expectedNumberofArgs > len(resultTypes) {
+		return nil, compileErrorf(exp.Rparen, Arity, "too few parameters to function call, expected %d, found %d", expectedNumberofArgs, len(resultTypes))
+	}
+	if expectedNumberofArgs < len(resultTypes) {
+		return nil, compileErrorf(exp.Rparen, Arity, "too many parameters to function call, expected %d, found %d", expectedNumberofArgs, len(resultTypes))
+	}
+	for i, typ := range resultTypes {
+		if !typ.AssignableTo(fnType.In(i + argOffset)) {
+			return nil, compileErrorf(exp.Args[0].Pos(), TypeMismatch, "type mismatch in argument %d", i)
+		}
+	}
+	return []compiledExpression{argExp}, nil
 }
 
 func evalCallExpr(pctx context.Context, exp *ast.CallExpr) compiledExpression {
+	if isTryBuiltin(pctx, exp) {
+		return evalTryExpr(pctx, exp)
+	}
 	fnExp := compile(pctx, exp.Fun)
 	if fnExp.Error() != nil {
-		return fnExp
+		exps := []compiledExpression{fnExp}
+		if compileOptionsFrom(pctx).allErrors {
+			for _, argExpr := range exp.Args {
+				exps = append(exps, compile(pctx, argExpr))
+			}
+		}
+		return collectErrors(pctx, exps...)
 	}
 	fnType, _ := fnExp.ReturnType()
 	if fnType.Kind() != reflect.Func {
 		if fnType.AssignableTo(TypeType) {
-			return newErrorExpression(errors.Errorf("%d: type conversion not supported", exp.Lparen))
+			return newErrorExpression(compileErrorf(exp.Lparen, UnsupportedExpression, "type conversion not supported"))
 		}
-		return newErrorExpression(errors.Errorf("%d: not a function", exp.Lparen))
+		return newErrorExpression(compileErrorf(exp.Lparen, NotAFunction, "not a function"))
 	}
 	if fnType.IsVariadic() {
-		return newErrorExpression(errors.Errorf("%d: variadic functions are not supported.", exp.Lparen))
+		return newErrorExpression(compileErrorf(exp.Lparen, UnsupportedExpression, "variadic functions are not supported."))
+	}
+	if fnType.NumOut() == 0 {
+		return newErrorExpression(compileErrorf(exp.Lparen, UnsupportedExpression, "%s has no result", types.ExprString(exp.Fun)))
 	}
 	returnsError := functionReturnsError(fnType)
-	argExps, err := functionArgs(pctx, fnExp.HasOwner(), fnType, exp)
+	withContext := acceptsContext(fnType)
+	argExps, spread, err := functionArgs(pctx, fnType, exp)
 	if err != nil {
 		return newErrorExpression(err)
 	}
 	var returnType reflect.Type
+	var resultTypes []reflect.Type
 	var thresholdArgs = 1
 	if returnsError {
 		thresholdArgs = 2
 	}
 	if fnType.NumOut() > thresholdArgs {
-		returnType = reflect.TypeOf([]reflect.Value{})
+		returnType = TupleType
+		for i := 0; i < fnType.NumOut()-thresholdArgs+1; i++ {
+			resultTypes = append(resultTypes, fnType.Out(i))
+		}
 	} else {
 		returnType = fnType.Out(0)
 	}
-	return &callCompiledExpression{nopExpression{exp}, exp, fnExp, argExps, returnsError, returnType}
+	options := compileOptionsFrom(pctx)
+	pure, policy := options.pure, options.policy
+	if sce, ok := fnExp.(*selectCompiledExpression); ok && sce.isMethod {
+		// Methods are checked here and by the member policy of the selector.
+		if pure != nil {
+			if err := pure.checkPureMethod(exp.Fun.(*ast.SelectorExpr).Sel.NamePos, sce.xtyp, sce.name); err != nil {
+				return newErrorExpression(err)
+			}
+		}
+		pure, policy = nil, nil
+	}
+	name := types.ExprString(exp.Fun)
+	var onError errorHandler
+	if returnsError {
+		onError = options.errorHandlers[name]
+		if err := checkErrorHandler(exp.Lparen, name, onError, returnType, resultTypes); err != nil {
+			return newErrorExpression(err)
+		}
+	}
+	return &callCompiledExpression{nopExpression{exp}, exp, fnExp, argExps, returnsError, withContext, returnType, resultTypes, spread, name, options.panicPolicy, options.panicHook, pure, onError, policy}
 }
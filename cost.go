@@ -0,0 +1,139 @@
+package goel
+
+import (
+	"github.com/pkg/errors"
+	"go/token"
+	"reflect"
+)
+
+// Cost is the estimated cost of a single execution of an expression, counted in the units of Budget.
+type Cost struct {
+	// MinSteps and MaxSteps bound the number of operations evaluated.  They differ when && or || may short-circuit.
+	MinSteps, MaxSteps int
+	// MinCalls and MaxCalls bound the number of function calls.
+	MinCalls, MaxCalls int
+}
+
+func (c Cost) add(o Cost) Cost {
+	return Cost{c.MinSteps + o.MinSteps, c.MaxSteps + o.MaxSteps, c.MinCalls + o.MinCalls, c.MaxCalls + o.MaxCalls}
+}
+
+// EstimateCost walks the compiled tree of cexp and returns the cost of executing it, excluding the cost of the
+// functions it calls.  A function passed a collection with a size hint declared by Env.DeclareSizeHint is taken as a
+// collection helper visiting each element: the elements add to MaxSteps and, for each function passed to the helper
+// along with the collection, to MaxCalls.  Budget does not count these.  cexp may be an Expression created by
+// CompileAs.  EstimateCost fails if cexp did not compile or was not compiled by goel.
+func EstimateCost(cexp CompiledExpression) (Cost, error) {
+	if err := cexp.Error(); err != nil {
+		return Cost{}, errors.Wrap(err, "cannot estimate the cost of an expression that did not compile")
+	}
+	if w, ok := cexp.(wrappedExpression); ok {
+		cexp = w.unwrap()
+	}
+	e, ok := cexp.(*expression)
+	if !ok {
+		return Cost{}, errors.Errorf("cannot estimate the cost of %T", cexp)
+	}
+	return e.root.cost(), nil
+}
+
+// costOf returns the sum of the costs of exps, ignoring nil expressions.
+func costOf(exps ...CompiledExpression) Cost {
+	var c Cost
+	for _, exp := range exps {
+		if exp, ok := exp.(compiledExpression); ok {
+			c = c.add(exp.cost())
+		}
+	}
+	return c
+}
+
+// sized is implemented by the expressions that may evaluate to a collection with a size hint.
+type sized interface {
+	// sizeHint returns the maximum number of elements of the collection or -1 if there is no size hint.
+	sizeHint() int
+}
+
+func (luivce *lookUpIdentifierValueCompiledExpression) sizeHint() int {
+	return luivce.size
+}
+
+func (sce *sliceCompiledExpression) sizeHint() int {
+	if x, ok := sce.xexp.(sized); ok {
+		return x.sizeHint()
+	}
+	return -1
+}
+
+// step is the cost of an operation that is counted as a step, excluding its operands.
+var step = Cost{MinSteps: 1, MaxSteps: 1}
+
+func (nop *nopExpression) cost() Cost {
+	return Cost{}
+}
+
+func (bce *binaryCompiledExpression) cost() Cost {
+	c := step.add(costOf(bce.left, bce.right))
+	if bce.op == token.LAND || bce.op == token.LOR {
+		left := step.add(costOf(bce.left))
+		c.MinSteps, c.MinCalls = left.MinSteps, left.MinCalls
+	}
+	return c
+}
+
+func (uce *unaryCompiledExpression) cost() Cost {
+	return step.add(costOf(uce.xexp))
+}
+
+func (luivce *lookUpIdentifierValueCompiledExpression) cost() Cost {
+	return step
+}
+
+func (sce *selectCompiledExpression) cost() Cost {
+	return step.add(costOf(sce.x))
+}
+
+func (ice *innerCompiledExpression) cost() Cost {
+	return step.add(costOf(ice.xexp, ice.iexp))
+}
+
+func (sce *sliceCompiledExpression) cost() Cost {
+	return step.add(costOf(sce.xexp, sce.lexp, sce.hexp, sce.mexp))
+}
+
+func (lce *lengthCompiledExpression) cost() Cost {
+	return costOf(lce.xexp)
+}
+
+func (tace *typeAssertionCompiledExpression) cost() Cost {
+	return step.add(costOf(tace.xexp))
+}
+
+func (cce *conversionCompiledExpression) cost() Cost {
+	return costOf(cce.xexp)
+}
+
+func (cce *callCompiledExpression) cost() Cost {
+	c := Cost{MinSteps: 1, MaxSteps: 1, MinCalls: 1, MaxCalls: 1}.add(costOf(cce.fnExp))
+	elements, funcs := 0, 0
+	for _, arg := range cce.args {
+		c = c.add(costOf(arg))
+		if s, ok := arg.(sized); ok && s.sizeHint() > 0 {
+			elements += s.sizeHint()
+		}
+		if typ, _ := arg.ReturnType(); typ != nil && typ.Kind() == reflect.Func {
+			funcs++
+		}
+	}
+	c.MaxSteps += elements
+	c.MaxCalls += elements * funcs
+	return c
+}
+
+func (tce *tryCompiledExpression) cost() Cost {
+	c := step.add(costOf(tce.xexp))
+	fallback := costOf(tce.fallback)
+	c.MaxSteps += fallback.MaxSteps
+	c.MaxCalls += fallback.MaxCalls
+	return c
+}
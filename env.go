@@ -0,0 +1,253 @@
+package goel
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+)
+
+// Env declares the identifiers available to an expression at compile time along with their types.  Use NewContext to
+// attach it to the parsing context.  An Env may have a parent, created with NewChild, whose declarations it inherits
+// unless it declares the same name itself.  Once it has children, an Env is sealed: it can no longer be modified and
+// may be shared by any number of goroutines.
+type Env struct {
+	parent    *Env
+	types     map[string]reflect.Type
+	sizeHints map[string]int
+	sealed    int32
+}
+
+// NewEnv creates an empty Env.
+func NewEnv() *Env {
+	return &Env{nil, make(map[string]reflect.Type), make(map[string]int), 0}
+}
+
+// NewChild seals the Env and creates an empty Env that inherits its declarations.
+func (env *Env) NewChild() *Env {
+	atomic.StoreInt32(&env.sealed, 1)
+	return &Env{env, make(map[string]reflect.Type), make(map[string]int), 0}
+}
+
+// Declare declares the identifier name with the type typ, shadowing any declaration of name by the parents of the
+// Env, and returns the Env so that calls can be chained.  Declare panics if the Env is sealed.
+func (env *Env) Declare(name string, typ reflect.Type) *Env {
+	if atomic.LoadInt32(&env.sealed) != 0 {
+		panic("goel: Declare called on a sealed Env")
+	}
+	env.types[name] = typ
+	return env
+}
+
+// DeclareSizeHint declares that the slice, array, map or string bound to the identifier name holds at most size
+// elements and returns the Env so that calls can be chained.  EstimateCost uses the hint to estimate the cost of the
+// functions the collection is passed to.  Declaring name again in a child of the Env drops the hint.
+// DeclareSizeHint panics if the Env is sealed.
+func (env *Env) DeclareSizeHint(name string, size int) *Env {
+	if atomic.LoadInt32(&env.sealed) != 0 {
+		panic("goel: DeclareSizeHint called on a sealed Env")
+	}
+	env.sizeHints[name] = size
+	return env
+}
+
+// sizeHint returns the size hint declared for name by the Env or its closest parent declaring name or a hint for it
+// and whether there is one.
+func (env *Env) sizeHint(name string) (int, bool) {
+	for e := env; e != nil; e = e.parent {
+		if size, ok := e.sizeHints[name]; ok {
+			return size, true
+		}
+		if _, ok := e.types[name]; ok {
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// Lookup returns the type declared for name by the Env or its closest parent declaring it and whether it was
+// declared.
+func (env *Env) Lookup(name string) (reflect.Type, bool) {
+	for e := env; e != nil; e = e.parent {
+		if typ, ok := e.types[name]; ok {
+			return typ, true
+		}
+	}
+	return nil, false
+}
+
+// NewContext returns a copy of ctx carrying the Env for use as the parsing context.
+func (env *Env) NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, envKey{}, env)
+}
+
+// Bindings supplies the values of the identifiers declared in an Env when an expression is executed.  Use NewContext
+// to attach it to the execution context.  Like an Env, Bindings may have a parent, created with NewChild, whose values
+// they inherit and are sealed once they have children.  Binding the values of a single execution in a child of shared
+// Bindings only costs as much as the number of values bound.
+type Bindings struct {
+	parent *Bindings
+	values map[string]binding
+	sealed int32
+}
+
+// binding is either a value or the resolver of a value bound with BindLazy.
+type binding struct {
+	value    reflect.Value
+	resolver *resolver
+	// receiver is the type of the receiver of a method bound by BindStruct.
+	receiver reflect.Type
+}
+
+// resolver is a function with the signature func(context.Context) (T, error) bound with BindLazy.
+type resolver struct {
+	fn reflect.Value
+}
+
+// NewBindings creates an empty set of Bindings.
+func NewBindings() *Bindings {
+	return &Bindings{nil, make(map[string]binding), 0}
+}
+
+// NewChild seals the Bindings and creates an empty set of Bindings that inherits their values.
+func (b *Bindings) NewChild() *Bindings {
+	atomic.StoreInt32(&b.sealed, 1)
+	return &Bindings{b, make(map[string]binding), 0}
+}
+
+// Bind binds the identifier name to value, shadowing any value bound to name by the parents of the Bindings, and
+// returns the Bindings so that calls can be chained.  value may be a reflect.Value.  Bind panics if the Bindings are
+// sealed.
+func (b *Bindings) Bind(name string, value interface{}) *Bindings {
+	b.verifyUnsealed("Bind")
+	v, ok := value.(reflect.Value)
+	if !ok {
+		v = reflect.ValueOf(value)
+	}
+	b.values[name] = binding{value: v}
+	return b
+}
+
+// BindLazy binds the identifier name to the value returned by fn, which must be a function with the signature
+// func(context.Context) (T, error).  fn is only called, with the execution context, if the execution of an expression
+// reaches the identifier and its result, or error, is reused for the rest of that execution.  Like Bind, BindLazy
+// shadows any value bound to name by the parents of the Bindings and panics if the Bindings are sealed or if fn does
+// not have the expected signature.
+func (b *Bindings) BindLazy(name string, fn interface{}) *Bindings {
+	b.verifyUnsealed("BindLazy")
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() || v.Type().IsVariadic() ||
+		v.Type().NumIn() != 1 || v.Type().In(0) != contextType ||
+		v.Type().NumOut() != 2 || v.Type().Out(1) != ErrorType {
+		panic(fmt.Sprintf("goel: BindLazy expected a func(context.Context) (T, error) but found %T", fn))
+	}
+	b.values[name] = binding{resolver: &resolver{v}}
+	return b
+}
+
+func (b *Bindings) verifyUnsealed(method string) {
+	if atomic.LoadInt32(&b.sealed) != 0 {
+		panic("goel: " + method + " called on sealed Bindings")
+	}
+}
+
+// Lookup returns the value bound to name by the Bindings or their closest parent binding it and whether it is bound.
+// The value of a lazy binding is its resolver function, which Lookup does not call.
+func (b *Bindings) Lookup(name string) (reflect.Value, bool) {
+	bd, ok := b.lookup(name)
+	if bd.resolver != nil {
+		return bd.resolver.fn, true
+	}
+	return bd.value, ok
+}
+
+func (b *Bindings) lookup(name string) (binding, bool) {
+	for bb := b; bb != nil; bb = bb.parent {
+		if bd, ok := bb.values[name]; ok {
+			return bd, true
+		}
+	}
+	return binding{}, false
+}
+
+// NewContext returns a copy of ctx carrying the Bindings for use as the execution context.
+func (b *Bindings) NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bindingsKey{}, b)
+}
+
+// EnvFromMap creates both the Env declaring and the Bindings binding each entry of vars.  The type of each identifier
+// is the type of its value or interface{} if the value is nil.  A value may be a reflect.Value.
+func EnvFromMap(vars map[string]interface{}) (*Env, *Bindings) {
+	env, b := NewEnv(), NewBindings()
+	for name, value := range vars {
+		v, ok := value.(reflect.Value)
+		if !ok {
+			v = reflect.ValueOf(value)
+		}
+		if v.IsValid() {
+			env.Declare(name, v.Type())
+		} else {
+			env.Declare(name, InterfaceType)
+		}
+		b.Bind(name, v)
+	}
+	return env, b
+}
+
+// Eval compiles src with the identifiers in vars declared, as described by EnvFromMap, and executes it.
+func Eval(src string, vars map[string]interface{}, opts ...CompileOption) (interface{}, error) {
+	env, b := EnvFromMap(vars)
+	cexp := Compile(env.NewContext(context.Background()), src, opts...)
+	if err := cexp.Error(); err != nil {
+		return nil, err
+	}
+	return cexp.Execute(b.NewContext(context.Background()))
+}
+
+type envKey struct{}
+
+type bindingsKey struct{}
+
+// lookupDeclaration returns the type declared for name by the Env of the parsing context or, for backward
+// compatibility, the value stored in the parsing context with name as the key.
+func lookupDeclaration(pctx context.Context, name string) interface{} {
+	if env, ok := pctx.Value(envKey{}).(*Env); ok {
+		if typ, ok := env.Lookup(name); ok {
+			return typ
+		}
+	}
+	return pctx.Value(name)
+}
+
+// lookupSizeHint returns the size hint declared for name by the Env of the parsing context and whether there is one.
+func lookupSizeHint(pctx context.Context, name string) (int, bool) {
+	if env, ok := pctx.Value(envKey{}).(*Env); ok {
+		return env.sizeHint(name)
+	}
+	return 0, false
+}
+
+// lookupMethodReceiver returns the type of the receiver of the method bound to name by BindStruct in the Bindings of
+// the execution context or nil if name is not bound to such a method.
+func lookupMethodReceiver(ectx context.Context, name string) reflect.Type {
+	if b, ok := ectx.Value(bindingsKey{}).(*Bindings); ok {
+		if bd, ok := b.lookup(name); ok {
+			return bd.receiver
+		}
+	}
+	return nil
+}
+
+// lookupBinding returns the value, or the *resolver, bound to name by the Bindings of the execution context or, for
+// backward compatibility, the value stored in the execution context with name as the key.
+func lookupBinding(ectx context.Context, name string) interface{} {
+	if b, ok := ectx.Value(bindingsKey{}).(*Bindings); ok {
+		if bd, ok := b.lookup(name); ok {
+			if bd.resolver != nil {
+				return bd.resolver
+			}
+			return bd.value
+		}
+	}
+	return ectx.Value(name)
+}
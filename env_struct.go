@@ -0,0 +1,169 @@
+package goel
+
+import (
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+)
+
+// structField is an exported field of a struct, possibly promoted from an embedded struct, along with the name it is
+// declared as.
+type structField struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+// EnvFromStruct creates an Env that declares each exported field and each exported method of the struct type typ as
+// an identifier.  typ may also be a pointer to a struct in which case the methods with pointer receivers are declared
+// as well.  A field is declared with the name given by its goel tag, its json tag or its own name, in that order of
+// preference, and is skipped if that name is "-".  The exported fields of embedded structs without a tag are declared
+// as if they were fields of typ, unless typ has a field or method with the same name.  Use BindStruct to bind the
+// values of the identifiers.
+func EnvFromStruct(typ reflect.Type) (*Env, error) {
+	if err := verifyStructType(typ); err != nil {
+		return nil, err
+	}
+	env := NewEnv()
+	for _, f := range structFields(typ) {
+		env.Declare(f.name, f.typ)
+	}
+	for i := 0; i < typ.NumMethod(); i++ {
+		m := typ.Method(i)
+		env.Declare(m.Name, methodValueType(m.Type))
+	}
+	return env, nil
+}
+
+// BindStruct creates the Bindings for the identifiers declared by EnvFromStruct from v, which must be a struct or a
+// non-nil pointer to a struct.
+func BindStruct(v interface{}) (*Bindings, error) {
+	value := reflect.ValueOf(v)
+	if err := verifyStructType(value.Type()); err != nil {
+		return nil, err
+	}
+	if value.Kind() == reflect.Ptr && value.IsNil() {
+		return nil, errors.Errorf("cannot bind a nil %s", value.Type())
+	}
+	b := NewBindings()
+	sv := reflect.Indirect(value)
+	for _, f := range structFields(value.Type()) {
+		fv, err := fieldByIndex(sv, f.index)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot bind %s", f.name)
+		}
+		b.Bind(f.name, fv)
+	}
+	for i := 0; i < value.NumMethod(); i++ {
+		b.values[value.Type().Method(i).Name] = binding{value: value.Method(i), receiver: value.Type()}
+	}
+	return b, nil
+}
+
+func verifyStructType(typ reflect.Type) error {
+	if typ == nil {
+		return errors.New("expected a struct but found nil")
+	}
+	if typ.Kind() == reflect.Struct || (typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Struct) {
+		return nil
+	}
+	return errors.Errorf("expected a struct or a pointer to a struct but found %s", typ)
+}
+
+// methodValueType returns the type of a method value, i.e. the type of the method without its receiver.
+func methodValueType(methodType reflect.Type) reflect.Type {
+	in := make([]reflect.Type, 0, methodType.NumIn()-1)
+	for i := 1; i < methodType.NumIn(); i++ {
+		in = append(in, methodType.In(i))
+	}
+	out := make([]reflect.Type, 0, methodType.NumOut())
+	for i := 0; i < methodType.NumOut(); i++ {
+		out = append(out, methodType.Out(i))
+	}
+	return reflect.FuncOf(in, out, methodType.IsVariadic())
+}
+
+// structFields returns the fields of the struct type typ, or the struct typ points to, that EnvFromStruct declares.
+// Like go, the fields of embedded structs are promoted level by level: a field shadows the fields with the same name
+// at deeper levels and the fields with the same name at the shallowest level they appear at are ambiguous and not
+// declared.
+func structFields(typ reflect.Type) []structField {
+	names := make(map[string]bool)
+	for i := 0; i < typ.NumMethod(); i++ {
+		names[typ.Method(i).Name] = true
+	}
+	var fields []structField
+	current := []embedding{{indirectType(typ), nil}}
+	seen := make(map[reflect.Type]bool)
+	for len(current) > 0 {
+		found := make(map[string][]structField)
+		var order []string
+		var next []embedding
+		for _, e := range current {
+			if seen[e.typ] {
+				continue
+			}
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+				index := append(append([]int{}, e.index...), i)
+				name, tagged := fieldName(sf)
+				if sf.Anonymous && !tagged && indirectType(sf.Type).Kind() == reflect.Struct {
+					next = append(next, embedding{indirectType(sf.Type), index})
+					continue
+				}
+				if sf.PkgPath != "" || name == "-" || names[name] {
+					continue
+				}
+				if found[name] == nil {
+					order = append(order, name)
+				}
+				found[name] = append(found[name], structField{name, index, sf.Type})
+			}
+		}
+		for _, name := range order {
+			names[name] = true
+			if len(found[name]) == 1 {
+				fields = append(fields, found[name][0])
+			}
+		}
+		for _, e := range current {
+			seen[e.typ] = true
+		}
+		current = next
+	}
+	return fields
+}
+
+// fieldName returns the name a field is declared as and whether the name was given by a tag.
+func fieldName(sf reflect.StructField) (string, bool) {
+	for _, key := range []string{"goel", "json"} {
+		if tag, ok := sf.Tag.Lookup(key); ok {
+			if name := strings.Split(tag, ",")[0]; name != "" {
+				return name, true
+			}
+		}
+	}
+	return sf.Name, false
+}
+
+func indirectType(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Ptr {
+		return typ.Elem()
+	}
+	return typ
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex but returns an error instead of panicking on a nil embedded
+// pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, errors.Errorf("nil pointer to embedded struct %s", v.Type().Elem())
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
@@ -0,0 +1,92 @@
+package goel
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+)
+
+// ErrorPolicy determines what a call of a function whose last result is an error evaluates to when the function
+// returns a non-nil error.
+type ErrorPolicy int
+
+const (
+	// ErrorAbort aborts the execution and returns the error from Execute.  This is the default.
+	ErrorAbort ErrorPolicy = iota
+	// ErrorZeroValue ignores the error and evaluates the call to the zero value of the result, e.g. false for a
+	// predicate.
+	ErrorZeroValue
+	// ErrorFallback ignores the error and evaluates the call to the fallback value given to OnError.
+	ErrorFallback
+)
+
+// errorHandler is the policy registered with OnError for a function.
+type errorHandler struct {
+	policy   ErrorPolicy
+	fallback interface{}
+}
+
+// OnError sets the policy applied when the function name returns a non-nil error.  name is the function expression
+// as it appears in the source, e.g. "lookup" or "user.Permissions".  fallback is only used with the ErrorFallback
+// policy and must be assignable to the result of the function.  The policy is ignored for functions that do not
+// return an error.
+func OnError(name string, policy ErrorPolicy, fallback interface{}) CompileOption {
+	return func(opts *compileOptions) {
+		if opts.errorHandlers == nil {
+			opts.errorHandlers = make(map[string]errorHandler)
+		}
+		opts.errorHandlers[name] = errorHandler{policy, fallback}
+	}
+}
+
+// checkErrorHandler verifies that the fallback of the handler of a call at pos can be the result of the call, whose
+// type is returnType.  For a function with several results, whose types are resultTypes, the fallback must be a Tuple
+// of values assignable to them.
+func checkErrorHandler(pos token.Pos, name string, handler errorHandler, returnType reflect.Type, resultTypes []reflect.Type) error {
+	if handler.policy != ErrorFallback {
+		return nil
+	}
+	if err := checkFallbackValue(pos, name, handler.fallback, returnType); err != nil || resultTypes == nil {
+		return err
+	}
+	t, _ := handler.fallback.(Tuple)
+	if len(t) != len(resultTypes) {
+		return compileErrorf(pos, TypeMismatch, "fallback of %s has %d values, expected %d", name, len(t), len(resultTypes))
+	}
+	for i, typ := range resultTypes {
+		if err := checkFallbackValue(pos, fmt.Sprintf("%s result %d", name, i), t[i], typ); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFallbackValue verifies that the fallback value of what is described by name is assignable to typ.
+func checkFallbackValue(pos token.Pos, name string, fallback interface{}, typ reflect.Type) error {
+	if fallback == nil {
+		if !isNillable(typ) {
+			return compileErrorf(pos, TypeMismatch, "fallback of %s is nil, expected %s", name, typ)
+		}
+		return nil
+	}
+	if ftyp := reflect.TypeOf(fallback); !ftyp.AssignableTo(typ) {
+		return compileErrorf(pos, TypeMismatch, "fallback of %s has type %s, expected %s", name, ftyp, typ)
+	}
+	return nil
+}
+
+// errorResult returns what the call evaluates to when the function returns a non-nil error under a policy other than
+// ErrorAbort.
+func (cce *callCompiledExpression) errorResult() interface{} {
+	if cce.onError.policy == ErrorFallback {
+		return cce.onError.fallback
+	}
+	if cce.resultTypes == nil {
+		return reflect.Zero(cce.returnType).Interface()
+	}
+	t := make(Tuple, 0, len(cce.resultTypes))
+	for _, typ := range cce.resultTypes {
+		t = append(t, reflect.Zero(typ).Interface())
+	}
+	return t
+}
@@ -0,0 +1,290 @@
+package goel
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"runtime"
+	"strings"
+)
+
+// ErrorKind classifies the errors reported when compiling or executing an expression.  An ErrorKind is itself an
+// error so that errors.Is(err, goel.TypeMismatch) reports whether err is a *CompileError or *RuntimeError of that kind.
+type ErrorKind int
+
+const (
+	// UnknownErrorKind is the kind of errors that do not fit any other kind.
+	UnknownErrorKind ErrorKind = iota
+	// SyntaxError reports an expression that could not be parsed.
+	SyntaxError
+	// UnsupportedExpression reports an expression, operator or type that goel does not support.
+	UnsupportedExpression
+	// UndefinedIdentifier reports an identifier or type name that is not defined in the context.
+	UndefinedIdentifier
+	// UnboundIdentifier reports an identifier that was declared when compiling but has no value when executing.
+	UnboundIdentifier
+	// UnknownSelector reports a field or method that does not exist.
+	UnknownSelector
+	// TypeMismatch reports a value or expression that does not have the expected type.
+	TypeMismatch
+	// Arity reports a function call with the wrong number of arguments.
+	Arity
+	// NotAFunction reports a call of something that is not a function.
+	NotAFunction
+	// IndexOutOfRange reports an index or slice bound that is outside of the indexed value.
+	IndexOutOfRange
+	// NilDereference reports a nil value where a non-nil value is required.
+	NilDereference
+	// ArithmeticError reports an integer division by zero or, with checked arithmetic, an integer overflow.
+	ArithmeticError
+	// Panic reports a panic in a function called by the expression.
+	Panic
+	// RuntimeFault reports any other fault that would have caused a panic while executing the expression.
+	RuntimeFault
+	// ResolverError reports an error returned by the resolver of an identifier bound with BindLazy.
+	ResolverError
+	// Canceled reports an execution stopped because its context was canceled or its deadline exceeded.
+	Canceled
+	// BudgetExceeded reports an execution that exceeded a limit of the Budget of its context.
+	BudgetExceeded
+	// LimitExceeded reports an expression that exceeds a limit set by the MaxNodes, MaxDepth, MaxLiteralLength or
+	// MaxIdentifiers options.
+	LimitExceeded
+	// PolicyViolation reports the use of a type, field or method that the Policy of the Sandbox option denies.
+	PolicyViolation
+	// Impure reports, in pure mode, a call of a function or method that is not registered as pure or a result through
+	// which the inputs could be modified.
+	Impure
+	// Unexported reports a reference to an unexported field or method.
+	Unexported
+)
+
+var errorKindNames = [...]string{
+	UnknownErrorKind:      "unknown error",
+	SyntaxError:           "syntax error",
+	UnsupportedExpression: "unsupported expression",
+	UndefinedIdentifier:   "undefined identifier",
+	UnboundIdentifier:     "unbound identifier",
+	UnknownSelector:       "unknown selector",
+	TypeMismatch:          "type mismatch",
+	Arity:                 "wrong number of arguments",
+	NotAFunction:          "not a function",
+	IndexOutOfRange:       "index out of range",
+	NilDereference:        "nil dereference",
+	ArithmeticError:       "arithmetic error",
+	Panic:                 "panic",
+	RuntimeFault:          "runtime fault",
+	ResolverError:         "resolver error",
+	Canceled:              "canceled",
+	BudgetExceeded:        "budget exceeded",
+	LimitExceeded:         "limit exceeded",
+	PolicyViolation:       "policy violation",
+	Impure:                "impure expression",
+	Unexported:            "unexported",
+}
+
+func (k ErrorKind) String() string {
+	if 0 <= int(k) && int(k) < len(errorKindNames) {
+		return errorKindNames[k]
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+func (k ErrorKind) Error() string {
+	return k.String()
+}
+
+// CompileError is the error reported when an expression can not be compiled.
+type CompileError struct {
+	// Kind classifies the error.
+	Kind ErrorKind
+	// Pos is the position of the error within the parsed expression.
+	Pos token.Pos
+	// Position is Pos resolved with the file set given by the FileSet option.  It is not valid without one.
+	Position token.Position
+	// Snippet is the line of source containing the error when the source is known.
+	Snippet string
+	// Msg describes the error.
+	Msg string
+	// Err is the underlying cause, if any.
+	Err error
+}
+
+func (ce *CompileError) Error() string {
+	return formatError(ce.Pos, ce.Position, ce.Msg)
+}
+
+// Unwrap returns the underlying cause of the error.
+func (ce *CompileError) Unwrap() error {
+	return ce.Err
+}
+
+// Is reports whether target is the ErrorKind of this error.
+func (ce *CompileError) Is(target error) bool {
+	kind, ok := target.(ErrorKind)
+	return ok && kind == ce.Kind
+}
+
+// RuntimeError is the error reported when the execution of an expression fails.
+type RuntimeError struct {
+	// Kind classifies the error.
+	Kind ErrorKind
+	// Pos is the position within the parsed expression at which execution failed.
+	Pos token.Pos
+	// Position is Pos resolved with the file set given by the FileSet option.  It is not valid without one.
+	Position token.Position
+	// Snippet is the line of source containing the error when the source is known.
+	Snippet string
+	// Msg describes the error.
+	Msg string
+	// Err is the underlying cause, if any.
+	Err error
+}
+
+func (re *RuntimeError) Error() string {
+	return formatError(re.Pos, re.Position, re.Msg)
+}
+
+// Unwrap returns the underlying cause of the error.
+func (re *RuntimeError) Unwrap() error {
+	return re.Err
+}
+
+// Is reports whether target is the ErrorKind of this error.
+func (re *RuntimeError) Is(target error) bool {
+	kind, ok := target.(ErrorKind)
+	return ok && kind == re.Kind
+}
+
+// ErrorList is the error reported when an expression compiled with the AllErrors option has errors.  The errors are
+// sorted by position.
+type ErrorList []*CompileError
+
+func (el ErrorList) Error() string {
+	switch len(el) {
+	case 0:
+		return "no errors"
+	case 1:
+		return el[0].Error()
+	case 2:
+		return fmt.Sprintf("%s (and 1 more error)", el[0])
+	}
+	return fmt.Sprintf("%s (and %d more errors)", el[0], len(el)-1)
+}
+
+// Unwrap returns the errors in the list.
+func (el ErrorList) Unwrap() []error {
+	errs := make([]error, 0, len(el))
+	for _, err := range el {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Is reports whether any of the errors in the list matches target.  errors.Is only unwraps lists of errors by itself
+// since go 1.20.
+func (el ErrorList) Is(target error) bool {
+	for _, err := range el {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error in the list that matches target and, if there is one, sets target to it.  errors.As only
+// unwraps lists of errors by itself since go 1.20.
+func (el ErrorList) As(target interface{}) bool {
+	for _, err := range el {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (el ErrorList) Len() int           { return len(el) }
+func (el ErrorList) Swap(i, j int)      { el[i], el[j] = el[j], el[i] }
+func (el ErrorList) Less(i, j int) bool { return el[i].Pos < el[j].Pos }
+
+// add appends err to the list, flattening it if it is itself a list.
+func (el ErrorList) add(err error) ErrorList {
+	switch err := err.(type) {
+	case ErrorList:
+		return append(el, err...)
+	case *CompileError:
+		return append(el, err)
+	default:
+		return append(el, &CompileError{Kind: UnknownErrorKind, Msg: err.Error(), Err: err})
+	}
+}
+
+func formatError(pos token.Pos, position token.Position, msg string) string {
+	if position.IsValid() {
+		return fmt.Sprintf("%s: %s", position, msg)
+	}
+	return fmt.Sprintf("%d: %s", pos, msg)
+}
+
+func compileErrorf(pos token.Pos, kind ErrorKind, format string, args ...interface{}) error {
+	return &CompileError{Kind: kind, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func runtimeErrorf(pos token.Pos, kind ErrorKind, format string, args ...interface{}) error {
+	return &RuntimeError{Kind: kind, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// runtimePanicError converts the value r recovered from a panic while executing the expression at pos to an error.
+func runtimePanicError(pos token.Pos, r interface{}) error {
+	switch r := r.(type) {
+	case runtime.Error:
+		msg := strings.TrimPrefix(r.Error(), "runtime error: ")
+		kind := RuntimeFault
+		switch {
+		case r == errIntegerOverflow || strings.Contains(msg, "divide by zero"):
+			kind = ArithmeticError
+		case strings.Contains(msg, "out of range"):
+			kind = IndexOutOfRange
+		case strings.Contains(msg, "nil pointer dereference") || strings.Contains(msg, "nil map"):
+			kind = NilDereference
+		}
+		return &RuntimeError{Kind: kind, Pos: pos, Msg: msg, Err: r}
+	case error:
+		return &RuntimeError{Kind: RuntimeFault, Pos: pos, Msg: r.Error(), Err: r}
+	default:
+		return runtimeErrorf(pos, RuntimeFault, "%v", r)
+	}
+}
+
+// resolvePositions resolves the positions of the goel errors in err with fset and fills in their snippets from src.
+func resolvePositions(err error, fset *token.FileSet, src string) {
+	if fset == nil {
+		return
+	}
+	switch e := err.(type) {
+	case ErrorList:
+		for _, ce := range e {
+			ce.Position, ce.Snippet = resolvePosition(ce.Pos, fset, src)
+		}
+	case *CompileError:
+		e.Position, e.Snippet = resolvePosition(e.Pos, fset, src)
+	case *RuntimeError:
+		e.Position, e.Snippet = resolvePosition(e.Pos, fset, src)
+	}
+}
+
+func resolvePosition(pos token.Pos, fset *token.FileSet, src string) (token.Position, string) {
+	if !pos.IsValid() || fset.File(pos) == nil {
+		return token.Position{}, ""
+	}
+	position := fset.Position(pos)
+	if position.Offset > len(src) {
+		return position, ""
+	}
+	start := strings.LastIndexByte(src[:position.Offset], '\n') + 1
+	end := strings.IndexByte(src[position.Offset:], '\n')
+	if end < 0 {
+		return position, src[start:]
+	}
+	return position, src[start : position.Offset+end]
+}
@@ -2,10 +2,14 @@ package goel
 
 import (
 	"context"
-	"github.com/pkg/errors"
 	"go/ast"
+	"go/parser"
+	"go/scanner"
 	"go/token"
+	"go/types"
 	"reflect"
+	"sort"
+	"strings"
 )
 
 var (
@@ -35,12 +39,14 @@ type CompiledExpression interface {
 	ReturnType() (reflect.Type, error)
 	// Error returns any building error that may have occurred.
 	Error() error
+	// Source returns the source of the expression.
+	Source() string
 }
 
 type compiledExpression interface {
 	CompiledExpression
-	HasOwner() bool
 	Pos() token.Pos
+	cost() Cost
 }
 
 type nopExpression struct {
@@ -48,6 +54,9 @@ type nopExpression struct {
 }
 
 func (nop *nopExpression) Pos() token.Pos {
+	if nop.exp == nil {
+		return token.NoPos
+	}
 	return nop.exp.Pos()
 }
 
@@ -63,8 +72,11 @@ func (nop *nopExpression) Error() error {
 	return nil
 }
 
-func (nop *nopExpression) HasOwner() bool {
-	return false
+func (nop *nopExpression) Source() string {
+	if nop.exp == nil {
+		return ""
+	}
+	return types.ExprString(nop.exp)
 }
 
 type errExpression struct {
@@ -88,12 +100,167 @@ func (ee *errExpression) Error() error {
 	return ee.err
 }
 
+// expression is the root of a compiled expression.  It resolves the positions of the errors reported by the tree.
+type expression struct {
+	root        compiledExpression
+	fset        *token.FileSet
+	src         string
+	identifiers []*ast.Ident
+	pure        bool
+}
+
+func (e *expression) Execute(executionContext context.Context) (interface{}, error) {
+	if err := e.root.Error(); err != nil {
+		return nil, err
+	}
+	if err := e.checkBindings(executionContext); err != nil {
+		resolvePositions(err, e.fset, e.src)
+		return nil, err
+	}
+	if err := checkCanceled(executionContext, e.root.Pos()); err != nil {
+		resolvePositions(err, e.fset, e.src)
+		return nil, err
+	}
+	defer repanicPropagated()
+	v, err := e.root.Execute(withExecutionState(executionContext))
+	if err == nil && e.pure {
+		err = pureResult(e.root.Pos(), v)
+	}
+	if err != nil {
+		resolvePositions(err, e.fset, e.src)
+		return nil, err
+	}
+	return v, nil
+}
+
+// checkBindings verifies that every identifier the expression looks up is bound in the execution context.
+func (e *expression) checkBindings(ectx context.Context) error {
+	var unbound []string
+	var pos token.Pos
+	seen := make(map[string]bool)
+	for _, ident := range e.identifiers {
+		if seen[ident.Name] {
+			continue
+		}
+		seen[ident.Name] = true
+		if lookupBinding(ectx, ident.Name) == nil {
+			if len(unbound) == 0 {
+				pos = ident.NamePos
+			}
+			unbound = append(unbound, ident.Name)
+		}
+	}
+	switch len(unbound) {
+	case 0:
+		return nil
+	case 1:
+		return runtimeErrorf(pos, UnboundIdentifier, "undefined identifier: %s", unbound[0])
+	}
+	return runtimeErrorf(pos, UnboundIdentifier, "undefined identifiers: %s", strings.Join(unbound, ", "))
+}
+
+func (e *expression) ReturnType() (reflect.Type, error) {
+	return e.root.ReturnType()
+}
+
+func (e *expression) Error() error {
+	return e.root.Error()
+}
+
+func (e *expression) Source() string {
+	if e.src != "" {
+		return e.src
+	}
+	return e.root.Source()
+}
+
+// collectErrors returns an expression reporting the compile errors of exps or nil if all of them compiled.  Unless
+// the AllErrors option is set, the first expression that failed to compile is returned as is.
+func collectErrors(pctx context.Context, exps ...compiledExpression) compiledExpression {
+	allErrors := compileOptionsFrom(pctx).allErrors
+	var errs ErrorList
+	for _, exp := range exps {
+		if exp == nil || exp.Error() == nil {
+			continue
+		}
+		if !allErrors {
+			return exp
+		}
+		errs = errs.add(exp.Error())
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return newErrorExpression(errs)
+}
+
+// Compile parses the source of an expression and creates an executable CompiledExpression using the parsing context.
+// The options alter how the expression is compiled.  Syntax errors are reported by the Error method of the result as
+// a *CompileError, or an ErrorList with the AllErrors option, just like the other compile errors.  The positions of
+// all errors are reported as line and column.
+func Compile(parseContext context.Context, src string, opts ...CompileOption) CompiledExpression {
+	fset := token.NewFileSet()
+	opts = append(append([]CompileOption(nil), opts...), FileSet(fset, src))
+	options := compileOptionsFrom(withCompileOptions(parseContext, opts))
+	mode := parser.Mode(0)
+	if options.allErrors {
+		mode = parser.AllErrors
+	}
+	base := fset.Base()
+	exp, err := parser.ParseExprFrom(fset, "", src, mode)
+	if err != nil {
+		var errs ErrorList
+		if list, ok := err.(scanner.ErrorList); ok {
+			for _, e := range list {
+				errs = append(errs, &CompileError{Kind: SyntaxError, Pos: token.Pos(base + e.Pos.Offset), Msg: e.Msg, Err: e})
+			}
+		} else {
+			errs = append(errs, &CompileError{Kind: SyntaxError, Pos: token.Pos(base), Msg: err.Error(), Err: err})
+		}
+		var root compiledExpression
+		if options.allErrors {
+			root = newErrorExpression(errs)
+		} else {
+			root = newErrorExpression(errs[0])
+		}
+		resolvePositions(root.Error(), fset, src)
+		return &expression{root, fset, src, nil, false}
+	}
+	return NewCompiledExpression(parseContext, exp, opts...)
+}
+
 // NewCompiledExpression takes a parsing context and an expression AST and creates an executable CompiledExpression.
-func NewCompiledExpression(parseContext context.Context, exp ast.Expr) CompiledExpression {
-	return compile(parseContext, exp)
+// The options alter how the expression is compiled.
+func NewCompiledExpression(parseContext context.Context, exp ast.Expr, opts ...CompileOption) CompiledExpression {
+	pctx := withCompileOptions(parseContext, opts)
+	options := compileOptionsFrom(pctx)
+	root := compileWithinLimits(pctx, exp)
+	e := &expression{root, options.fset, options.src, options.identifiers, options.pure != nil}
+	if err := checkPureExpression(options, e.root); err != nil {
+		e.root = newErrorExpression(err)
+	}
+	if err := checkResultType(options, e.root); err != nil {
+		e.root = newErrorExpression(err)
+	}
+	if err := e.root.Error(); err != nil {
+		if options.allErrors {
+			errs := ErrorList(nil).add(err)
+			sort.Stable(errs)
+			e.root, err = newErrorExpression(errs), errs
+		}
+		resolvePositions(err, e.fset, e.src)
+	}
+	return e
 }
 
 func compile(ctx context.Context, exp ast.Expr) compiledExpression {
+	options := compileOptionsFrom(ctx)
+	options.enter(exp)
+	defer options.leave()
+	return checkPolicy(options, exp, compileNode(ctx, exp))
+}
+
+func compileNode(ctx context.Context, exp ast.Expr) compiledExpression {
 	switch exp := exp.(type) {
 	case *ast.BinaryExpr:
 		return evalBinaryExpr(ctx, exp)
@@ -116,6 +283,6 @@ func compile(ctx context.Context, exp ast.Expr) compiledExpression {
 	case *ast.SliceExpr:
 		return evalSliceExpr(ctx, exp)
 	default:
-		return newErrorExpression(errors.Errorf("%d: unknown expression type", exp.Pos()))
+		return newErrorExpression(compileErrorf(exp.Pos(), UnsupportedExpression, "unknown expression type"))
 	}
 }
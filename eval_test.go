@@ -2,15 +2,22 @@ package goel_test
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"github.com/homedepot/goel"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"go/parser"
+	"go/token"
+	"math"
 	"net/http"
+	"os"
 	"reflect"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func ExampleNewCompiledExpression() {
@@ -47,6 +54,49 @@ func ExampleNewCompiledExpression() {
 	// 8
 }
 
+func ExampleCompile() {
+	pctx := context.WithValue(context.Background(), "x", goel.IntType)
+	ectx := context.WithValue(context.Background(), "x", reflect.ValueOf(5))
+	cexp := goel.Compile(pctx, "x * 3")
+	result, _ := cexp.Execute(ectx)
+	fmt.Printf("%s = %v\n", cexp.Source(), result)
+
+	cexp = goel.Compile(pctx, "x *\n\ty")
+	fmt.Println(cexp.Error())
+	// Output:
+	// x * 3 = 15
+	// 2:2: unknown identifier: y
+}
+
+func ExampleEnv() {
+	env := goel.NewEnv().
+		Declare("qty", goel.IntType).
+		Declare("price", goel.IntType)
+	cexp := goel.Compile(env.NewContext(context.Background()), "qty * price")
+
+	bindings := goel.NewBindings().
+		Bind("qty", 3).
+		Bind("price", 5)
+	result, _ := cexp.Execute(bindings.NewContext(context.Background()))
+	fmt.Println(result)
+
+	_, err := cexp.Execute(goel.NewBindings().Bind("qty", 3).NewContext(context.Background()))
+	fmt.Println(err)
+	// Output:
+	// 15
+	// 1:7: undefined identifier: price
+}
+
+func ExampleEval() {
+	result, _ := goel.Eval(`name + " ordered " + item`, map[string]interface{}{
+		"name": "Joe",
+		"item": "a hammer",
+	})
+	fmt.Println(result)
+	// Output:
+	// Joe ordered a hammer
+}
+
 type test struct {
 	name                   string
 	expression             string
@@ -56,6 +106,7 @@ type test struct {
 	expectedExecutionError error
 	parsingContext         map[string]interface{}
 	executionContext       map[string]interface{}
+	compileOptions         []goel.CompileOption
 }
 
 var testRequest *http.Request
@@ -462,6 +513,100 @@ func init() {
 			expression:            "3.5 / 2",
 			expectedBuildingError: errors.Errorf("5: type mismatch in binary expression"),
 		},
+		{
+			name:           "lenient numerics int times float",
+			expression:     "qty * price",
+			expectedValue:  reflect.ValueOf(7.5),
+			compileOptions: []goel.CompileOption{goel.LenientNumerics()},
+			parsingContext: map[string]interface{}{
+				"qty":   goel.IntType,
+				"price": goel.DoubleType,
+			},
+			executionContext: map[string]interface{}{
+				"qty":   reflect.ValueOf(3),
+				"price": reflect.ValueOf(2.5),
+			},
+		},
+		{
+			name:           "lenient numerics float literal divided by int literal",
+			expression:     "3.5 / 2",
+			expectedValue:  reflect.ValueOf(1.75),
+			compileOptions: []goel.CompileOption{goel.LenientNumerics()},
+		},
+		{
+			name:           "lenient numerics int plus int64",
+			expression:     "x + y",
+			expectedValue:  reflect.ValueOf(int64(7)),
+			compileOptions: []goel.CompileOption{goel.LenientNumerics()},
+			parsingContext: map[string]interface{}{
+				"x": goel.IntType,
+				"y": reflect.TypeOf(int64(0)),
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(2),
+				"y": reflect.ValueOf(int64(5)),
+			},
+		},
+		{
+			name:           "lenient numerics int compared to uint8",
+			expression:     "x < y",
+			expectedValue:  reflect.ValueOf(true),
+			compileOptions: []goel.CompileOption{goel.LenientNumerics()},
+			parsingContext: map[string]interface{}{
+				"x": goel.IntType,
+				"y": reflect.TypeOf(uint8(0)),
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(-2),
+				"y": reflect.ValueOf(uint8(5)),
+			},
+		},
+		{
+			name:           "lenient numerics uint16 plus uint32",
+			expression:     "x + y",
+			expectedValue:  reflect.ValueOf(uint64(7)),
+			compileOptions: []goel.CompileOption{goel.LenientNumerics()},
+			parsingContext: map[string]interface{}{
+				"x": reflect.TypeOf(uint16(0)),
+				"y": reflect.TypeOf(uint32(0)),
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(uint16(2)),
+				"y": reflect.ValueOf(uint32(5)),
+			},
+		},
+		{
+			name:           "lenient numerics int minus uint64",
+			expression:     "x - y",
+			expectedValue:  reflect.ValueOf(-3.0),
+			compileOptions: []goel.CompileOption{goel.LenientNumerics()},
+			parsingContext: map[string]interface{}{
+				"x": goel.IntType,
+				"y": reflect.TypeOf(uint64(0)),
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(2),
+				"y": reflect.ValueOf(uint64(5)),
+			},
+		},
+		{
+			name:           "lenient numerics complex times int",
+			expression:     "x * 2",
+			expectedValue:  reflect.ValueOf(complex(2, 4)),
+			compileOptions: []goel.CompileOption{goel.LenientNumerics()},
+			parsingContext: map[string]interface{}{
+				"x": reflect.TypeOf(complex64(0)),
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(complex64(complex(1, 2))),
+			},
+		},
+		{
+			name:                  "lenient numerics does not promote strings",
+			expression:            `"5" + 2`,
+			expectedBuildingError: errors.Errorf("5: type mismatch in binary expression"),
+			compileOptions:        []goel.CompileOption{goel.LenientNumerics()},
+		},
 		{
 			name:                  "unsupported type subtraction",
 			expression:            "'f' - 2",
@@ -1207,9 +1352,242 @@ func init() {
 				"f": reflect.ValueOf(variadicSum),
 			},
 		},
+		{
+			name:                   "integer division by zero",
+			expression:             "5 / x",
+			expectedExecutionError: errors.New("3: integer divide by zero"),
+			parsingContext: map[string]interface{}{
+				"x": goel.IntType,
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(0),
+			},
+		},
+		{
+			name:                   "integer modulo by zero",
+			expression:             "5 % x",
+			expectedExecutionError: errors.New("3: integer divide by zero"),
+			parsingContext: map[string]interface{}{
+				"x": goel.IntType,
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(0),
+			},
+		},
+		{
+			name:                   "negative index",
+			expression:             "a[x]",
+			expectedExecutionError: errors.New("3: index out of bounds, index = -1 must not be negative"),
+			parsingContext: map[string]interface{}{
+				"a": reflect.TypeOf([]int{}),
+				"x": goel.IntType,
+			},
+			executionContext: map[string]interface{}{
+				"a": reflect.ValueOf([]int{1, 2, 3}),
+				"x": reflect.ValueOf(-1),
+			},
+		},
+		{
+			name:          "string index",
+			expression:    "a[1]",
+			expectedValue: reflect.ValueOf(byte('o')),
+			parsingContext: map[string]interface{}{
+				"a": goel.StringType,
+			},
+			executionContext: map[string]interface{}{
+				"a": reflect.ValueOf("foo"),
+			},
+		},
+		{
+			name:                   "nil pointer dereference in selector",
+			expression:             "req.Method",
+			expectedExecutionError: errors.New("1: nil pointer dereference"),
+			parsingContext: map[string]interface{}{
+				"req": reflect.TypeOf(testRequest),
+			},
+			executionContext: map[string]interface{}{
+				"req": reflect.ValueOf((*http.Request)(nil)),
+			},
+		},
+		{
+			name:                   "nil pointer dereference in method call",
+			expression:             "req.Context()",
+			expectedExecutionError: errors.New("12: panic in req.Context: runtime error: invalid memory address or nil pointer dereference"),
+			parsingContext: map[string]interface{}{
+				"req": reflect.TypeOf(testRequest),
+			},
+			executionContext: map[string]interface{}{
+				"req": reflect.ValueOf((*http.Request)(nil)),
+			},
+		},
+		{
+			name:          "integer overflow wraps by default",
+			expression:    "x + 1",
+			expectedValue: reflect.ValueOf(math.MinInt),
+			parsingContext: map[string]interface{}{
+				"x": goel.IntType,
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(math.MaxInt),
+			},
+		},
+		{
+			name:                   "checked arithmetic addition overflow",
+			expression:             "x + 1",
+			expectedExecutionError: errors.New("3: integer overflow"),
+			compileOptions:         []goel.CompileOption{goel.CheckedArithmetic()},
+			parsingContext: map[string]interface{}{
+				"x": goel.IntType,
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(math.MaxInt),
+			},
+		},
+		{
+			name:                   "checked arithmetic multiplication overflow",
+			expression:             "x * 2",
+			expectedExecutionError: errors.New("3: integer overflow"),
+			compileOptions:         []goel.CompileOption{goel.CheckedArithmetic()},
+			parsingContext: map[string]interface{}{
+				"x": goel.IntType,
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(math.MaxInt),
+			},
+		},
+		{
+			name:                   "checked arithmetic negation overflow",
+			expression:             "-x",
+			expectedExecutionError: errors.New("1: integer overflow"),
+			compileOptions:         []goel.CompileOption{goel.CheckedArithmetic()},
+			parsingContext: map[string]interface{}{
+				"x": goel.IntType,
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(math.MinInt),
+			},
+		},
+		{
+			name:          "int64 overflow wraps by default",
+			expression:    "x + y",
+			expectedValue: reflect.ValueOf(int64(math.MinInt64)),
+			parsingContext: map[string]interface{}{
+				"x": goel.Int64Type,
+				"y": goel.Int64Type,
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(int64(math.MaxInt64)),
+				"y": reflect.ValueOf(int64(1)),
+			},
+		},
+		{
+			name:                   "checked arithmetic int64 addition overflow",
+			expression:             "x + y",
+			expectedExecutionError: errors.New("3: integer overflow"),
+			compileOptions:         []goel.CompileOption{goel.CheckedArithmetic()},
+			parsingContext: map[string]interface{}{
+				"x": goel.Int64Type,
+				"y": goel.Int64Type,
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(int64(math.MaxInt64)),
+				"y": reflect.ValueOf(int64(1)),
+			},
+		},
+		{
+			name:                   "checked arithmetic int64 multiplication overflow",
+			expression:             "x * y",
+			expectedExecutionError: errors.New("3: integer overflow"),
+			compileOptions:         []goel.CompileOption{goel.CheckedArithmetic()},
+			parsingContext: map[string]interface{}{
+				"x": goel.Int64Type,
+				"y": goel.Int64Type,
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(int64(math.MinInt64)),
+				"y": reflect.ValueOf(int64(-1)),
+			},
+		},
+		{
+			name:                   "checked arithmetic uint64 subtraction overflow",
+			expression:             "x - y",
+			expectedExecutionError: errors.New("3: integer overflow"),
+			compileOptions:         []goel.CompileOption{goel.CheckedArithmetic()},
+			parsingContext: map[string]interface{}{
+				"x": goel.Uint64Type,
+				"y": goel.Uint64Type,
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(uint64(0)),
+				"y": reflect.ValueOf(uint64(1)),
+			},
+		},
+		{
+			name:                   "checked arithmetic uint64 addition overflow",
+			expression:             "x + y",
+			expectedExecutionError: errors.New("3: integer overflow"),
+			compileOptions:         []goel.CompileOption{goel.CheckedArithmetic()},
+			parsingContext: map[string]interface{}{
+				"x": goel.Uint64Type,
+				"y": goel.Uint64Type,
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(uint64(math.MaxUint64)),
+				"y": reflect.ValueOf(uint64(1)),
+			},
+		},
+		{
+			name:           "checked arithmetic without overflow",
+			expression:     "x * 2 - 1",
+			expectedValue:  reflect.ValueOf(9),
+			compileOptions: []goel.CompileOption{goel.CheckedArithmetic()},
+			parsingContext: map[string]interface{}{
+				"x": goel.IntType,
+			},
+			executionContext: map[string]interface{}{
+				"x": reflect.ValueOf(5),
+			},
+		},
+		{
+			name:                   "panic in function is recovered",
+			expression:             `panics("Boo!")`,
+			expectedExecutionError: errors.New("7: panic in panics: Boo!"),
+			parsingContext: map[string]interface{}{
+				"panics": reflect.TypeOf(panics),
+			},
+			executionContext: map[string]interface{}{
+				"panics": reflect.ValueOf(panics),
+			},
+		},
+		{
+			name:                   "panic in method is recovered",
+			expression:             `ts.SetName("Jill")`,
+			expectedExecutionError: errors.New("11: panic in ts.SetName: runtime error: invalid memory address or nil pointer dereference"),
+			parsingContext: map[string]interface{}{
+				"ts": reflect.TypeOf(&ts),
+			},
+			executionContext: map[string]interface{}{
+				"ts": reflect.ValueOf((*testStruct)(nil)),
+			},
+		},
+		{
+			name:                  "all errors reported",
+			expression:            `x + f(y, 5 * "5") && z[0:w]`,
+			expectedBuildingError: errors.New(`1: unknown identifier: x (and 5 more errors)`),
+			compileOptions:        []goel.CompileOption{goel.AllErrors()},
+		},
+		{
+			name:                  "first error reported without all errors",
+			expression:            `x + f(y, 5 * "5") && z[0:w]`,
+			expectedBuildingError: errors.New(`1: unknown identifier: x`),
+		},
 	}
 }
 
+func panics(msg string) bool {
+	panic(msg)
+}
+
 func returnsNilFunction() func(regex, str string) bool {
 	return nil
 }
@@ -1250,7 +1628,7 @@ func TestCompile(t *testing.T) {
 			exp, err := parser.ParseExpr(tst.expression)
 			if tst.expectedParsingError == nil {
 				if assert.NoError(t, err) {
-					cexp := goel.NewCompiledExpression(pctx, exp)
+					cexp := goel.NewCompiledExpression(pctx, exp, tst.compileOptions...)
 					if tst.expectedBuildingError == nil {
 						if assert.NoError(t, cexp.Error()) {
 							actual, err := cexp.Execute(ectx)
@@ -1285,6 +1663,1303 @@ func TestCompile(t *testing.T) {
 	}
 }
 
+func TestPanicPolicy(t *testing.T) {
+	pctx := contextFromMap(map[string]interface{}{"panics": reflect.TypeOf(panics)})
+	ectx := contextFromMap(map[string]interface{}{"panics": reflect.ValueOf(panics)})
+	exp, err := parser.ParseExpr(`panics("Boo!")`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	cexp := goel.NewCompiledExpression(pctx, exp, goel.OnPanic(goel.PanicPropagate, nil))
+	if assert.NoError(t, cexp.Error()) {
+		assert.PanicsWithValue(t, "Boo!", func() { cexp.Execute(ectx) })
+	}
+	nested := goel.Compile(pctx, `panics("Boo!") == true && true`, goel.OnPanic(goel.PanicPropagate, nil))
+	if assert.NoError(t, nested.Error()) {
+		assert.PanicsWithValue(t, "Boo!", func() { nested.Execute(ectx) })
+	}
+
+	var logged *goel.PanicError
+	hook := func(ctx context.Context, err *goel.PanicError) {
+		logged = err
+	}
+	cexp = goel.NewCompiledExpression(pctx, exp, goel.OnPanic(goel.PanicRecoverAndLog, hook))
+	if assert.NoError(t, cexp.Error()) {
+		_, err := cexp.Execute(ectx)
+		if assert.True(t, stderrors.Is(err, goel.Panic)) {
+			var pe *goel.PanicError
+			if assert.True(t, stderrors.As(err, &pe)) {
+				assert.Equal(t, logged, pe)
+				assert.Equal(t, "panics", pe.Func)
+				assert.Equal(t, "Boo!", pe.Value)
+				assert.NotEmpty(t, pe.Stack)
+			}
+		}
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	pctx := contextFromMap(map[string]interface{}{"x": goel.IntType, "s": goel.StringType})
+	ectx := contextFromMap(map[string]interface{}{"x": reflect.ValueOf(0), "s": reflect.ValueOf("foo")})
+
+	src := "x > 0 &&\n\ts == 5"
+	fset := token.NewFileSet()
+	exp, err := parser.ParseExprFrom(fset, "rule", src, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	cexp := goel.NewCompiledExpression(pctx, exp, goel.FileSet(fset, src))
+	err = cexp.Error()
+	assert.EqualError(t, err, "rule:2:4: type mismatch in binary expression")
+	assert.True(t, stderrors.Is(err, goel.TypeMismatch))
+	assert.False(t, stderrors.Is(err, goel.UndefinedIdentifier))
+	var ce *goel.CompileError
+	if assert.True(t, stderrors.As(err, &ce)) {
+		assert.Equal(t, goel.TypeMismatch, ce.Kind)
+		assert.Equal(t, 2, ce.Position.Line)
+		assert.Equal(t, 4, ce.Position.Column)
+		assert.Equal(t, "\ts == 5", ce.Snippet)
+	}
+
+	src = "5 / x"
+	fset = token.NewFileSet()
+	exp, err = parser.ParseExprFrom(fset, "", src, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	cexp = goel.NewCompiledExpression(pctx, exp, goel.FileSet(fset, src))
+	if assert.NoError(t, cexp.Error()) {
+		_, err = cexp.Execute(ectx)
+		assert.EqualError(t, err, "1:3: integer divide by zero")
+		var re *goel.RuntimeError
+		if assert.True(t, stderrors.As(err, &re)) {
+			assert.Equal(t, goel.ArithmeticError, re.Kind)
+			assert.Equal(t, src, re.Snippet)
+		}
+	}
+
+	cexp = goel.Compile(pctx, "s[-x:]")
+	if assert.NoError(t, cexp.Error()) {
+		_, err = cexp.Execute(contextFromMap(map[string]interface{}{"x": reflect.ValueOf(1), "s": reflect.ValueOf("foo")}))
+		assert.EqualError(t, err, "1:3: index out of range: -1")
+	}
+}
+
+func TestAllErrors(t *testing.T) {
+	pctx := contextFromMap(map[string]interface{}{"f": reflect.TypeOf(matchesRegex)})
+	src := `x + f(y, 5 * "5") && f("a", 5)`
+	fset := token.NewFileSet()
+	exp, err := parser.ParseExprFrom(fset, "", src, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+	cexp := goel.NewCompiledExpression(pctx, exp, goel.AllErrors(), goel.FileSet(fset, src))
+	var errs goel.ErrorList
+	if assert.True(t, stderrors.As(cexp.Error(), &errs)) {
+		actual := make([]string, 0, len(errs))
+		for _, err := range errs {
+			actual = append(actual, err.Error())
+		}
+		assert.Equal(t, []string{
+			"1:1: unknown identifier: x",
+			"1:7: unknown identifier: y",
+			"1:12: type mismatch in binary expression",
+			"1:29: type mismatch in argument 1",
+		}, actual)
+		// The list matches by itself, without relying on errors.Is and errors.As unwrapping lists.
+		assert.True(t, errs.Is(goel.UndefinedIdentifier))
+		assert.False(t, errs.Is(goel.Arity))
+		var ce *goel.CompileError
+		if assert.True(t, errs.As(&ce)) {
+			assert.Equal(t, "1:1: unknown identifier: x", ce.Error())
+		}
+	}
+	assert.True(t, stderrors.Is(cexp.Error(), goel.TypeMismatch))
+}
+
+func TestCompileDoesNotModifyOptions(t *testing.T) {
+	pctx := goel.NewEnv().Declare("s", goel.StringType).NewContext(context.Background())
+	opts := make([]goel.CompileOption, 1, 2)
+	opts[0] = goel.AllErrors()
+	first := goel.Compile(pctx, `s +`, opts...)
+	second := goel.Compile(pctx, `s + 1`, opts...)
+	assert.EqualError(t, first.Error(), "1:4: expected operand, found 'EOF'")
+	assert.EqualError(t, second.Error(), "1:3: type mismatch in binary expression")
+	assert.Nil(t, opts[:2][1])
+}
+
+func TestCompileSource(t *testing.T) {
+	pctx := context.Background()
+	cexp := goel.Compile(pctx, "5x")
+	assert.EqualError(t, cexp.Error(), "1:2: expected 'EOF', found x")
+	var ce *goel.CompileError
+	if assert.True(t, stderrors.As(cexp.Error(), &ce)) {
+		assert.Equal(t, goel.SyntaxError, ce.Kind)
+		assert.Equal(t, "5x", ce.Snippet)
+	}
+	_, err := cexp.ReturnType()
+	assert.Equal(t, cexp.Error(), err)
+	_, err = cexp.Execute(context.Background())
+	assert.Equal(t, cexp.Error(), err)
+	assert.Equal(t, "5x", cexp.Source())
+
+	cexp = goel.Compile(pctx, "(5 +) * (3 -)", goel.AllErrors())
+	var errs goel.ErrorList
+	if assert.True(t, stderrors.As(cexp.Error(), &errs)) && assert.True(t, len(errs) > 1) {
+		assert.Equal(t, "1:5: expected operand, found ')'", errs[0].Error())
+		assert.True(t, stderrors.Is(errs[1], goel.SyntaxError))
+	}
+
+	exp, err := parser.ParseExpr("(5 + 3) * 2")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "(5 + 3) * 2", goel.NewCompiledExpression(pctx, exp).Source())
+	}
+}
+
+func TestEnv(t *testing.T) {
+	env := goel.NewEnv().Declare("x", goel.IntType).Declare("y", goel.IntType)
+	// identifiers not declared in the Env are still looked up in the parsing context.
+	pctx := context.WithValue(env.NewContext(context.Background()), "z", goel.IntType)
+	cexp := goel.Compile(pctx, "x + y + z")
+	if !assert.NoError(t, cexp.Error()) {
+		return
+	}
+	ectx := context.WithValue(goel.NewBindings().Bind("x", 1).Bind("y", reflect.ValueOf(2)).NewContext(context.Background()), "z", reflect.ValueOf(3))
+	result, err := cexp.Execute(ectx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 6, result)
+	}
+
+	_, err = cexp.Execute(goel.NewBindings().Bind("y", 2).NewContext(context.Background()))
+	assert.EqualError(t, err, "1:1: undefined identifiers: x, z")
+	assert.True(t, stderrors.Is(err, goel.UnboundIdentifier))
+
+	_, err = cexp.Execute(goel.NewBindings().Bind("x", "1").Bind("y", 2).Bind("z", 3).NewContext(context.Background()))
+	assert.True(t, stderrors.Is(err, goel.TypeMismatch))
+
+	cexp = goel.Compile(goel.NewEnv().Declare("err", goel.ErrorType).NewContext(context.Background()), "err")
+	result, err = cexp.Execute(goel.NewBindings().Bind("err", nil).NewContext(context.Background()))
+	if assert.NoError(t, err) {
+		assert.Nil(t, result)
+	}
+}
+
+type address struct {
+	City string `json:"city"`
+	Zip  string
+}
+
+type node struct {
+	*node
+	X int
+}
+
+type order struct {
+	Qty      int     `json:"qty"`
+	Price    float64 `goel:"unitPrice" json:"price"`
+	Internal string  `goel:"-"`
+	Zip      int
+	secret   string
+	*address
+}
+
+type promotedDeep struct {
+	X int
+}
+
+type promotedA struct {
+	promotedDeep
+	Y int
+}
+
+type promotedB struct {
+	X int
+	Y int
+}
+
+type promoted struct {
+	promotedA
+	promotedB
+}
+
+func (o order) Total() float64 {
+	return float64(o.Qty) * o.Price
+}
+
+func (o *order) Discount(pct float64) float64 {
+	return o.Total() * pct / 100
+}
+
+func TestEnvFromStruct(t *testing.T) {
+	o := &order{Qty: 3, Price: 2.5, Internal: "x", Zip: 5, secret: "y", address: &address{"Atlanta", "30339"}}
+	env, err := goel.EnvFromStruct(reflect.TypeOf(o))
+	if !assert.NoError(t, err) {
+		return
+	}
+	for name, typ := range map[string]reflect.Type{
+		"qty":       goel.IntType,
+		"unitPrice": goel.DoubleType,
+		"Zip":       goel.IntType,
+		"city":      goel.StringType,
+		"Total":     reflect.TypeOf(func() float64 { return 0 }),
+		"Discount":  reflect.TypeOf(func(float64) float64 { return 0 }),
+	} {
+		actual, ok := env.Lookup(name)
+		if assert.True(t, ok, name) {
+			assert.Equal(t, typ, actual, name)
+		}
+	}
+	for _, name := range []string{"Qty", "Price", "price", "Internal", "secret", "address", "City"} {
+		_, ok := env.Lookup(name)
+		assert.False(t, ok, name)
+	}
+
+	bindings, err := goel.BindStruct(o)
+	if !assert.NoError(t, err) {
+		return
+	}
+	cexp := goel.Compile(env.NewContext(context.Background()), `Total() - Discount(10.0) == 6.75 && city == "Atlanta" && qty == Zip - 2`)
+	if assert.NoError(t, cexp.Error()) {
+		result, err := cexp.Execute(bindings.NewContext(context.Background()))
+		if assert.NoError(t, err) {
+			assert.Equal(t, true, result)
+		}
+	}
+
+	_, err = goel.EnvFromStruct(goel.IntType)
+	assert.EqualError(t, err, "expected a struct or a pointer to a struct but found int")
+	_, err = goel.BindStruct((*order)(nil))
+	assert.EqualError(t, err, "cannot bind a nil *goel_test.order")
+	_, err = goel.BindStruct(order{})
+	assert.EqualError(t, err, "cannot bind city: nil pointer to embedded struct goel_test.address")
+
+	env, err = goel.EnvFromStruct(reflect.TypeOf(node{}))
+	if assert.NoError(t, err) {
+		typ, ok := env.Lookup("X")
+		assert.True(t, ok)
+		assert.Equal(t, goel.IntType, typ)
+	}
+	bindings, err = goel.BindStruct(node{X: 1})
+	if assert.NoError(t, err) {
+		v, ok := bindings.Lookup("X")
+		assert.True(t, ok)
+		assert.Equal(t, 1, v.Interface())
+	}
+
+	env, err = goel.EnvFromStruct(reflect.TypeOf(promoted{}))
+	if assert.NoError(t, err) {
+		_, ok := env.Lookup("Y")
+		assert.False(t, ok)
+	}
+	bindings, err = goel.BindStruct(promoted{promotedA{promotedDeep{1}, 3}, promotedB{2, 4}})
+	if assert.NoError(t, err) {
+		v, ok := bindings.Lookup("X")
+		assert.True(t, ok)
+		assert.Equal(t, 2, v.Interface())
+		_, ok = bindings.Lookup("Y")
+		assert.False(t, ok)
+	}
+}
+
+func TestEval(t *testing.T) {
+	vars := map[string]interface{}{
+		"x":   5,
+		"y":   reflect.ValueOf(2),
+		"err": nil,
+		"m":   map[string]int{"a": 1},
+	}
+	result, err := goel.Eval(`x * y + m["a"]`, vars)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 11, result)
+	}
+	result, err = goel.Eval(`err`, vars)
+	if assert.NoError(t, err) {
+		assert.Nil(t, result)
+	}
+	env, _ := goel.EnvFromMap(vars)
+	typ, ok := env.Lookup("err")
+	if assert.True(t, ok) {
+		assert.Equal(t, goel.InterfaceType, typ)
+	}
+	_, err = goel.Eval(`x * z`, vars)
+	assert.EqualError(t, err, "1:5: unknown identifier: z")
+	_, err = goel.Eval(`x / (y - 2)`, vars)
+	assert.EqualError(t, err, "1:3: integer divide by zero")
+}
+
+func TestScopes(t *testing.T) {
+	globals := goel.NewEnv().Declare("rate", goel.DoubleType).Declare("name", goel.StringType)
+	env := globals.NewChild().Declare("qty", goel.DoubleType)
+	assert.Panics(t, func() { globals.Declare("qty", goel.IntType) })
+	typ, ok := env.Lookup("rate")
+	if assert.True(t, ok) {
+		assert.Equal(t, goel.DoubleType, typ)
+	}
+	_, ok = globals.Lookup("qty")
+	assert.False(t, ok)
+	cexp := goel.Compile(env.NewContext(context.Background()), `qty * rate`)
+	if !assert.NoError(t, cexp.Error()) {
+		return
+	}
+	shared := goel.NewBindings().Bind("rate", 0.5).Bind("name", "global")
+	tenant := shared.NewChild().Bind("rate", 0.25)
+	assert.Panics(t, func() { shared.Bind("rate", 1.0) })
+	var wg sync.WaitGroup
+	results := make([]interface{}, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = cexp.Execute(tenant.NewChild().Bind("qty", float64(i)).NewContext(context.Background()))
+		}(i)
+	}
+	wg.Wait()
+	for i, result := range results {
+		assert.Equal(t, float64(i)*0.25, result)
+	}
+	name, ok := tenant.Lookup("name")
+	if assert.True(t, ok) {
+		assert.Equal(t, "global", name.Interface())
+	}
+	_, err := cexp.Execute(tenant.NewContext(context.Background()))
+	assert.EqualError(t, err, "1:1: undefined identifier: qty")
+}
+
+func TestBindLazy(t *testing.T) {
+	env := goel.NewEnv().Declare("enabled", goel.BoolType).Declare("score", goel.IntType).Declare("err", goel.ErrorType)
+	pctx := env.NewContext(context.Background())
+	calls := 0
+	score := func(ctx context.Context) (int, error) {
+		calls++
+		return 7, nil
+	}
+	cexp := goel.Compile(pctx, `enabled && score > 5 && score < 10`)
+	if !assert.NoError(t, cexp.Error()) {
+		return
+	}
+	b := goel.NewBindings().Bind("enabled", false).BindLazy("score", score)
+	result, err := cexp.Execute(b.NewContext(context.Background()))
+	if assert.NoError(t, err) {
+		assert.Equal(t, false, result)
+	}
+	assert.Equal(t, 0, calls)
+	b = goel.NewBindings().Bind("enabled", true).BindLazy("score", score)
+	result, err = cexp.Execute(b.NewContext(context.Background()))
+	if assert.NoError(t, err) {
+		assert.Equal(t, true, result)
+	}
+	assert.Equal(t, 1, calls)
+	_, err = cexp.Execute(b.NewContext(context.Background()))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	unavailable := stderrors.New("unavailable")
+	b = goel.NewBindings().Bind("enabled", true).BindLazy("score", func(ctx context.Context) (int, error) {
+		return 0, unavailable
+	})
+	_, err = cexp.Execute(b.NewContext(context.Background()))
+	assert.EqualError(t, err, "1:12: cannot resolve score: unavailable")
+	assert.True(t, stderrors.Is(err, goel.ResolverError))
+	assert.True(t, stderrors.Is(err, unavailable))
+
+	cexp = goel.Compile(pctx, `err`)
+	b = goel.NewBindings().BindLazy("err", func(ctx context.Context) (error, error) {
+		return nil, nil
+	})
+	result, err = cexp.Execute(b.NewContext(context.Background()))
+	if assert.NoError(t, err) {
+		assert.Nil(t, result)
+	}
+	assert.Panics(t, func() { goel.NewBindings().BindLazy("score", func() int { return 7 }) })
+
+	calls = 0
+	b = goel.NewBindings().Bind("enabled", true).BindLazy("score", func(ctx context.Context) (int, error) {
+		calls++
+		panic("boom")
+	})
+	cexp = goel.Compile(pctx, `enabled && (score > 5 || score < 0)`)
+	_, err = cexp.Execute(b.NewContext(context.Background()))
+	assert.EqualError(t, err, "1:13: panic in score: boom")
+	assert.True(t, stderrors.Is(err, goel.Panic))
+	var pe *goel.PanicError
+	if assert.True(t, stderrors.As(err, &pe)) {
+		assert.Equal(t, "score", pe.Func)
+		assert.Equal(t, "boom", pe.Value)
+	}
+	assert.Equal(t, 1, calls)
+	var logged *goel.PanicError
+	cexp = goel.Compile(pctx, `score > 5`, goel.OnPanic(goel.PanicRecoverAndLog, func(ctx context.Context, err *goel.PanicError) {
+		logged = err
+	}))
+	_, err = cexp.Execute(b.NewContext(context.Background()))
+	assert.True(t, stderrors.Is(err, goel.Panic))
+	assert.NotNil(t, logged)
+	cexp = goel.Compile(pctx, `score > 5`, goel.OnPanic(goel.PanicPropagate, nil))
+	assert.PanicsWithValue(t, "boom", func() { _, _ = cexp.Execute(b.NewContext(context.Background())) })
+}
+
+type requestIDKey struct{}
+
+type settings map[string]string
+
+func (s settings) Get(ctx context.Context, key string) string {
+	return ctx.Value(requestIDKey{}).(string) + ":" + s[key]
+}
+
+type getter interface {
+	Get(ctx context.Context, key string) string
+}
+
+func TestContextInjection(t *testing.T) {
+	requestID := func(ctx context.Context) string {
+		return ctx.Value(requestIDKey{}).(string)
+	}
+	env := goel.NewEnv().
+		Declare("requestID", reflect.TypeOf(requestID)).
+		Declare("s", reflect.TypeOf(settings{})).
+		Declare("g", reflect.TypeOf((*getter)(nil)).Elem())
+	pctx := env.NewContext(context.Background())
+	b := goel.NewBindings().Bind("requestID", requestID).Bind("s", settings{"tier": "gold"}).Bind("g", settings{"tier": "silver"})
+	ectx := b.NewContext(context.WithValue(context.Background(), requestIDKey{}, "r1"))
+	for expression, expected := range map[string]string{
+		`requestID()`:                       "r1",
+		`s.Get("tier")`:                     "r1:gold",
+		`g.Get("tier")`:                     "r1:silver",
+		`requestID() + "/" + s.Get("tier")`: "r1/r1:gold",
+	} {
+		cexp := goel.Compile(pctx, expression)
+		if !assert.NoError(t, cexp.Error(), expression) {
+			continue
+		}
+		result, err := cexp.Execute(ectx)
+		if assert.NoError(t, err, expression) {
+			assert.Equal(t, expected, result, expression)
+		}
+	}
+	assert.EqualError(t, goel.Compile(pctx, `requestID("r2")`).Error(), "1:15: too many parameters to function call, expected 0, found 1")
+	assert.EqualError(t, goel.Compile(pctx, `s.Get()`).Error(), "1:7: too few parameters to function call, expected 1, found 0")
+}
+
+func TestCancellation(t *testing.T) {
+	env := goel.NewEnv().Declare("x", goel.IntType).Declare("slow", reflect.TypeOf(func(context.Context) int { return 0 }))
+	pctx := env.NewContext(context.Background())
+	b := goel.NewBindings().Bind("slow", func(ctx context.Context) int {
+		<-ctx.Done()
+		return 1
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := goel.Compile(pctx, `x + 1`).Execute(b.NewChild().Bind("x", 1).NewContext(ctx))
+	assert.EqualError(t, err, "1:1: context canceled")
+	assert.True(t, stderrors.Is(err, goel.Canceled))
+	assert.True(t, stderrors.Is(err, context.Canceled))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = goel.Compile(pctx, `1 + slow()`).Execute(b.NewChild().Bind("x", 1).NewContext(ctx))
+	assert.EqualError(t, err, "1:10: context deadline exceeded")
+	assert.True(t, stderrors.Is(err, context.DeadlineExceeded))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	src := "x" + strings.Repeat(" + x", 200)
+	_, err = goel.Compile(pctx, src).Execute(b.NewChild().BindLazy("x", func(context.Context) (int, error) {
+		cancel()
+		return 1, nil
+	}).NewContext(ctx))
+	if assert.Error(t, err) {
+		assert.True(t, stderrors.Is(err, goel.Canceled))
+		assert.True(t, stderrors.Is(err, context.Canceled))
+	}
+}
+
+func TestBudget(t *testing.T) {
+	env := goel.NewEnv().
+		Declare("x", goel.IntType).
+		Declare("s", goel.StringType).
+		Declare("f", reflect.TypeOf(func() int { return 0 }))
+	pctx := env.NewContext(context.Background())
+	b := goel.NewBindings().Bind("x", 1).Bind("s", "abcd").Bind("f", func() int { return 1 })
+	for _, tt := range []struct {
+		expression string
+		budget     goel.Budget
+		expected   string
+		limit      goel.BudgetLimit
+	}{
+		{`x + x + x`, goel.Budget{MaxSteps: 3}, "1:5: budget exceeded: more than 3 steps", goel.StepLimit},
+		{`f() + f() + f()`, goel.Budget{MaxCalls: 2}, "1:14: budget exceeded: more than 2 function calls", goel.CallLimit},
+		{`s + s + s`, goel.Budget{MaxBytes: 10}, "1:7: budget exceeded: more than 10 bytes", goel.ByteLimit},
+		{`s[1:3] + s[:]`, goel.Budget{MaxBytes: 5}, "1:11: budget exceeded: more than 5 bytes", goel.ByteLimit},
+		{`s + s + s`, goel.Budget{MaxSteps: 5, MaxCalls: 1, MaxBytes: 20}, "", 0},
+		{`f() + f() + f() + x`, goel.Budget{}, "", 0},
+	} {
+		cexp := goel.Compile(pctx, tt.expression)
+		if !assert.NoError(t, cexp.Error(), tt.expression) {
+			continue
+		}
+		_, err := cexp.Execute(goel.WithBudget(b.NewContext(context.Background()), tt.budget))
+		if tt.expected == "" {
+			assert.NoError(t, err, tt.expression)
+			continue
+		}
+		assert.EqualError(t, err, tt.expected, tt.expression)
+		assert.True(t, stderrors.Is(err, goel.BudgetExceeded), tt.expression)
+		var be *goel.BudgetError
+		if assert.True(t, stderrors.As(err, &be), tt.expression) {
+			assert.Equal(t, tt.limit, be.Limit, tt.expression)
+		}
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	env := goel.NewEnv().
+		Declare("x", goel.IntType).
+		Declare("s", goel.StringType).
+		Declare("ok", goel.BoolType).
+		Declare("f", reflect.TypeOf(func(int) int { return 0 })).
+		Declare("items", reflect.TypeOf([]int{})).
+		DeclareSizeHint("items", 10).
+		Declare("count", reflect.TypeOf(count)).
+		Declare("even", reflect.TypeOf(even)).
+		Declare("contains", reflect.TypeOf(contains))
+	pctx := env.NewContext(context.Background())
+	b := goel.NewBindings().
+		Bind("x", 1).
+		Bind("s", "abcd").
+		Bind("ok", true).
+		Bind("f", func(i int) int { return i }).
+		Bind("items", []int{1, 2, 3}).
+		Bind("count", count).
+		Bind("even", even).
+		Bind("contains", contains)
+	for _, tt := range []struct {
+		expression string
+		expected   goel.Cost
+	}{
+		{`1`, goel.Cost{}},
+		{`x + 1`, goel.Cost{MinSteps: 2, MaxSteps: 2}},
+		{`f(x) + f(f(2))`, goel.Cost{MinSteps: 8, MaxSteps: 8, MinCalls: 3, MaxCalls: 3}},
+		{`s[1:] + s[:2]`, goel.Cost{MinSteps: 6, MaxSteps: 6}},
+		{`ok || f(x) > 0`, goel.Cost{MinSteps: 2, MaxSteps: 6, MaxCalls: 1}},
+		{`!ok && -x < 0`, goel.Cost{MinSteps: 3, MaxSteps: 6}},
+		{`count(items, even)`, goel.Cost{MinSteps: 4, MaxSteps: 14, MinCalls: 1, MaxCalls: 11}},
+		{`contains(items[1:], x)`, goel.Cost{MinSteps: 6, MaxSteps: 16, MinCalls: 1, MaxCalls: 1}},
+	} {
+		cexp := goel.Compile(pctx, tt.expression)
+		cost, err := goel.EstimateCost(cexp)
+		if !assert.NoError(t, err, tt.expression) {
+			continue
+		}
+		assert.Equal(t, tt.expected, cost, tt.expression)
+		budget := goel.Budget{MaxSteps: cost.MaxSteps, MaxCalls: cost.MaxCalls}
+		_, err = cexp.Execute(goel.WithBudget(b.NewContext(context.Background()), budget))
+		assert.NoError(t, err, tt.expression)
+	}
+	_, err := goel.EstimateCost(goel.Compile(pctx, `y`))
+	assert.EqualError(t, err, "cannot estimate the cost of an expression that did not compile: 1:1: unknown identifier: y")
+
+	typed, err := goel.CompileAs[bool](pctx, `ok || f(x) > 0`)
+	if assert.NoError(t, err) {
+		cost, err := goel.EstimateCost(typed)
+		assert.NoError(t, err)
+		assert.Equal(t, goel.Cost{MinSteps: 2, MaxSteps: 6, MaxCalls: 1}, cost)
+	}
+
+	// A declaration in a child Env drops the size hint of its parent.
+	child := env.NewChild().Declare("items", reflect.TypeOf([]int{}))
+	cost, err := goel.EstimateCost(goel.Compile(child.NewContext(context.Background()), `count(items, even)`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, goel.Cost{MinSteps: 4, MaxSteps: 4, MinCalls: 1, MaxCalls: 1}, cost)
+	}
+}
+
+func count(items []int, pred func(int) bool) int {
+	n := 0
+	for _, i := range items {
+		if pred(i) {
+			n++
+		}
+	}
+	return n
+}
+
+func even(i int) bool {
+	return i%2 == 0
+}
+
+func contains(items []int, x int) bool {
+	for _, i := range items {
+		if i == x {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCompileLimits(t *testing.T) {
+	env := goel.NewEnv().Declare("a", goel.IntType).Declare("b", goel.IntType).Declare("c", goel.IntType)
+	pctx := env.NewContext(context.Background())
+	deep := strings.Repeat("(", 10000) + "a" + strings.Repeat(")", 10000)
+	for _, tt := range []struct {
+		expression string
+		option     goel.CompileOption
+		expected   string
+	}{
+		{deep, goel.MaxDepth(100), "1:101: expression is nested more than 100 levels deep"},
+		{`a + b + c`, goel.MaxNodes(4), "1:9: expression has more than 4 nodes"},
+		{`"abc" + "defgh"`, goel.MaxLiteralLength(6), `1:9: literal is longer than 6 bytes`},
+		{`a + b * a - c`, goel.MaxIdentifiers(2), "1:13: expression uses more than 2 identifiers"},
+		{`(a + b) * c != 0 == true`, goel.MaxNodes(10), ""},
+		{`(a + b) * c`, goel.MaxDepth(4), ""},
+		{`a + b * a > 0 == true`, goel.MaxIdentifiers(2), ""},
+	} {
+		err := goel.Compile(pctx, tt.expression, tt.option, goel.AllErrors()).Error()
+		if tt.expected == "" {
+			assert.NoError(t, err, tt.expression)
+			continue
+		}
+		assert.EqualError(t, err, tt.expected, tt.expression)
+		assert.True(t, stderrors.Is(err, goel.LimitExceeded), tt.expression)
+	}
+}
+
+type sandboxedOrder struct {
+	*testStruct
+}
+
+func TestSandbox(t *testing.T) {
+	tsType := reflect.TypeOf(testStruct{})
+	env := goel.NewEnv().
+		Declare("ts", reflect.PtrTo(tsType)).
+		Declare("o", reflect.TypeOf(&sandboxedOrder{})).
+		Declare("req", reflect.TypeOf(testRequest)).
+		Declare("typ", goel.TypeType).
+		Declare("names", reflect.TypeOf(map[string][]*testStruct{}))
+	pctx := env.NewContext(context.Background())
+	ectx := goel.NewBindings().
+		Bind("ts", &testStruct{1, 2, "Joe"}).
+		Bind("o", &sandboxedOrder{&testStruct{1, 2, "Joe"}}).
+		NewContext(context.Background())
+	for _, tt := range []struct {
+		expression string
+		policy     *goel.Policy
+		expected   string
+	}{
+		{`ts.SetName("Bob")`, goel.NewPolicy().DenyMembers(tsType, "SetName"), "1:4: use of goel_test.testStruct.SetName is not permitted"},
+		{`ts.X`, goel.NewPolicy().AllowMembers(tsType, "GetName", "Name"), "1:4: use of goel_test.testStruct.X is not permitted"},
+		{`req.Method`, goel.NewPolicy().DenyPackages("net/http", "reflect"), "1:1: use of type *http.Request is not permitted"},
+		{`typ`, goel.NewPolicy().DenyPackages("net/http", "reflect"), "1:1: use of type *reflect.rtype is not permitted"},
+		{`req.Method`, goel.NewPolicy().AllowPackages("github.com/homedepot/goel_test"), "1:1: use of type *http.Request is not permitted"},
+		{`names["x"]`, goel.NewPolicy().DenyTypes(tsType), "1:1: use of type map[string][]*goel_test.testStruct is not permitted"},
+		{`ts.Name`, goel.NewPolicy().DenyTypes(goel.StringType), "1:1: use of type string is not permitted"},
+		{`ts.GetName() + ts.Name`, goel.NewPolicy().AllowMembers(tsType, "GetName", "Name").AllowPackages("github.com/homedepot/goel_test"), ""},
+		{`ts.X + ts.Y`, goel.NewPolicy().DenyMembers(tsType, "SetName").DenyPackages("net/http"), ""},
+		{`o.SetName("Bob")`, goel.NewPolicy().DenyMembers(tsType, "SetName"), "1:3: use of goel_test.testStruct.SetName is not permitted"},
+		{`o.X`, goel.NewPolicy().DenyMembers(tsType, "X"), "1:3: use of goel_test.testStruct.X is not permitted"},
+		{`o.X`, goel.NewPolicy().DenyMembers(reflect.TypeOf(sandboxedOrder{}), "X"), "1:3: use of goel_test.sandboxedOrder.X is not permitted"},
+		{`o.GetName()`, goel.NewPolicy().DenyMembers(tsType, "SetName"), ""},
+		{`req.Method`, goel.NewPolicy().DenyPackages("net"), "1:1: use of type *http.Request is not permitted"},
+		{`ts.X`, goel.NewPolicy().DenyPackages("github.com/homedepot/goel_te"), ""},
+	} {
+		runEvalTest(t, pctx, ectx, evalTest{tt.expression, tt.expected, nil, ""}, goel.PolicyViolation, goel.Sandbox(tt.policy))
+	}
+}
+
+func TestSandboxFuncs(t *testing.T) {
+	o := sandboxedOrder{&testStruct{1, 2, "Joe"}}
+	env, err := goel.EnvFromStruct(reflect.TypeOf(o))
+	if !assert.NoError(t, err) {
+		return
+	}
+	env = env.NewChild().
+		Declare("getenv", reflect.TypeOf(os.Getenv)).
+		Declare("upper", reflect.TypeOf(strings.ToUpper)).
+		Declare("lower", reflect.TypeOf(strings.ToLower))
+	b, err := goel.BindStruct(o)
+	if !assert.NoError(t, err) {
+		return
+	}
+	ectx := b.NewChild().
+		Bind("getenv", os.Getenv).
+		Bind("upper", strings.ToUpper).
+		Bind("lower", strings.ToLower).
+		NewContext(context.Background())
+	pctx := env.NewContext(context.Background())
+	for _, tt := range []struct {
+		expression string
+		policy     *goel.Policy
+		expected   string
+	}{
+		{`getenv("HOME")`, goel.NewPolicy().DenyPackages("os"), "1:7: call of getenv is not permitted"},
+		{`upper("a")`, goel.NewPolicy().DenyPackages("os"), ""},
+		{`upper("a")`, goel.NewPolicy().DenyFuncs(strings.ToUpper), "1:6: call of upper is not permitted"},
+		{`lower("a")`, goel.NewPolicy().DenyFuncs(strings.ToUpper), ""},
+		{`lower("a")`, goel.NewPolicy().AllowFuncs(strings.ToUpper), "1:6: call of lower is not permitted"},
+		{`upper("a")`, goel.NewPolicy().AllowFuncs(strings.ToUpper), ""},
+		{`GetName()`, goel.NewPolicy().DenyMembers(reflect.TypeOf(testStruct{}), "GetName"), "1:8: call of GetName is not permitted"},
+		{`GetName()`, goel.NewPolicy().DenyPackages("reflect"), ""},
+	} {
+		runEvalTest(t, pctx, ectx, evalTest{tt.expression, "", nil, tt.expected}, goel.PolicyViolation, goel.Sandbox(tt.policy))
+	}
+	assert.Panics(t, func() { goel.NewPolicy().DenyFuncs("upper") })
+}
+
+func TestPure(t *testing.T) {
+	tsType := reflect.TypeOf(testStruct{})
+	upper := strings.ToUpper
+	env := goel.NewEnv().
+		Declare("ts", reflect.PtrTo(tsType)).
+		Declare("ng", ngType).
+		Declare("upper", reflect.TypeOf(upper)).
+		Declare("lower", reflect.TypeOf(strings.ToLower)).
+		Declare("v", goel.InterfaceType).
+		Declare("scores", reflect.TypeOf(map[string]int{}))
+	pctx := env.NewContext(context.Background())
+	registry := goel.NewPureRegistry().Funcs(upper).Types(tsType).Methods(ngType, "GetName")
+	joe := &testStruct{1, 2, "Joe"}
+	ectx := goel.NewBindings().
+		Bind("ts", joe).
+		Bind("ng", ng).
+		Bind("upper", upper).
+		Bind("lower", strings.ToLower).
+		Bind("v", joe).
+		Bind("scores", map[string]int{"a": 1}).
+		NewContext(context.Background())
+	runEvalTests(t, pctx, ectx, []evalTest{
+		{`upper(ts.GetName()) + ng.GetName()`, "", "JOEJoe", ""},
+		{`ts.X + scores["a"]`, "", 2, ""},
+		{`ts.SetName("Bob")`, "1:4: method goel_test.testStruct.SetName has a pointer receiver", nil, ""},
+		{`ts`, "1:1: result of type *goel_test.testStruct could modify the inputs", nil, ""},
+		{`scores`, "1:1: result of type map[string]int could modify the inputs", nil, ""},
+		{`ts.GetName`, "1:1: result of type func() string could modify the inputs", nil, ""},
+		{`lower(ts.Name)`, "", nil, "1:6: function lower is not registered as pure"},
+		{`v`, "", nil, "1:1: result of type *goel_test.testStruct could modify the inputs"},
+	}, goel.Impure, goel.Pure(registry))
+	assert.Equal(t, "Joe", joe.Name)
+	cexp := goel.Compile(pctx, `ng.GetName()`, goel.Pure(goel.NewPureRegistry()))
+	assert.EqualError(t, cexp.Error(), "1:4: method goel_test.NameGetter.GetName is not registered as pure")
+
+	// A pointer method promoted through an embedded pointer is rejected like any other pointer method.
+	o := pureOuter{&pureInner{}}
+	octx := goel.NewEnv().Declare("o", reflect.TypeOf(o)).NewContext(context.Background())
+	cexp = goel.Compile(octx, `o.Inc()`, goel.Pure(goel.NewPureRegistry().Types(reflect.TypeOf(o))))
+	assert.EqualError(t, cexp.Error(), "1:3: method goel_test.pureInner.Inc has a pointer receiver")
+	cexp = goel.Compile(octx, `o.Get()`, goel.Pure(goel.NewPureRegistry().Types(reflect.TypeOf(o))))
+	assert.NoError(t, cexp.Error())
+
+	// The methods bound by BindStruct are identified by their receiver and name.
+	senv, err := goel.EnvFromStruct(reflect.TypeOf(o))
+	if !assert.NoError(t, err) {
+		return
+	}
+	sb, err := goel.BindStruct(o)
+	if !assert.NoError(t, err) {
+		return
+	}
+	registry = goel.NewPureRegistry().Types(reflect.TypeOf(o))
+	for expression, expected := range map[string]string{
+		`Get()`: "",
+		`Inc()`: "1:4: method goel_test.pureInner.Inc has a pointer receiver",
+	} {
+		cexp = goel.Compile(senv.NewContext(context.Background()), expression, goel.Pure(registry))
+		if !assert.NoError(t, cexp.Error(), expression) {
+			continue
+		}
+		_, err = cexp.Execute(sb.NewContext(context.Background()))
+		if expected == "" {
+			assert.NoError(t, err, expression)
+		} else {
+			assert.EqualError(t, err, expected, expression)
+		}
+	}
+	assert.Equal(t, 0, o.N)
+	assert.Panics(t, func() { goel.NewPureRegistry().Funcs(reflect.ValueOf(o).MethodByName("Get").Interface()) })
+}
+
+type pureInner struct {
+	N int
+}
+
+func (i *pureInner) Inc() int {
+	i.N++
+	return i.N
+}
+
+func (i pureInner) Get() int {
+	return i.N
+}
+
+type pureOuter struct {
+	*pureInner
+}
+
+type secret struct {
+	token  string
+	Public int
+}
+
+type wrapper struct {
+	secret
+	Name  string
+	count *int
+}
+
+func (w wrapper) hash() int {
+	return len(w.token)
+}
+
+func TestUnexported(t *testing.T) {
+	count := 3
+	w := wrapper{secret{"abc", 7}, "w", &count}
+	env := goel.NewEnv().Declare("w", reflect.TypeOf(w)).Declare("pw", reflect.TypeOf(&w))
+	pctx := env.NewContext(context.Background())
+	ectx := goel.NewBindings().Bind("w", w).Bind("pw", &w).NewContext(context.Background())
+	for _, tt := range []struct {
+		expression string
+		opts       []goel.CompileOption
+		expected   interface{}
+		err        string
+	}{
+		{`w.token`, nil, nil, "1:3: cannot refer to unexported field token of goel_test.wrapper"},
+		{`pw.secret`, nil, nil, "1:4: cannot refer to unexported field secret of *goel_test.wrapper"},
+		{`w.hash()`, nil, nil, "1:3: cannot refer to unexported method or field hash of goel_test.wrapper"},
+		{`w.Public + 1`, nil, 8, ""},
+		{`w.token + pw.token`, []goel.CompileOption{goel.AllowUnexported()}, "abcabc", ""},
+		{`w.count`, []goel.CompileOption{goel.AllowUnexported()}, &count, ""},
+		{`pw.secret.Public`, []goel.CompileOption{goel.AllowUnexported()}, 7, ""},
+		{`w.hash()`, []goel.CompileOption{goel.AllowUnexported()}, nil, "1:3: cannot refer to unexported method or field hash of goel_test.wrapper"},
+	} {
+		cexp := goel.Compile(pctx, tt.expression, tt.opts...)
+		if tt.err != "" {
+			assert.EqualError(t, cexp.Error(), tt.err, tt.expression)
+			continue
+		}
+		if !assert.NoError(t, cexp.Error(), tt.expression) {
+			continue
+		}
+		result, err := cexp.Execute(ectx)
+		if assert.NoError(t, err, tt.expression) {
+			assert.Equal(t, tt.expected, result, tt.expression)
+		}
+	}
+	assert.True(t, stderrors.Is(goel.Compile(pctx, `w.token`).Error(), goel.Unexported))
+}
+
+type counter struct {
+	N int
+}
+
+func (c counter) Describe() string {
+	return fmt.Sprintf("counter %d", c.N)
+}
+
+func (c *counter) Inc() int {
+	c.N++
+	return c.N
+}
+
+type gauge struct {
+	N int
+}
+
+func (g gauge) Describe() string {
+	return fmt.Sprintf("gauge %d", g.N)
+}
+
+type metric struct {
+	*counter
+	Label string
+	Scale func(int) int
+}
+
+type ambiguous struct {
+	counter
+	gauge
+}
+
+type shadowed struct {
+	counter
+	N string
+}
+
+type overridden struct {
+	counter
+	gauge
+}
+
+func (o overridden) Describe() string {
+	return "overridden"
+}
+
+type holder struct {
+	Inner counter
+	Items []counter
+}
+
+func TestSelectors(t *testing.T) {
+	m := metric{&counter{1}, "m", func(i int) int { return i * 2 }}
+	h := &holder{counter{1}, []counter{{5}}}
+	env := goel.NewEnv().
+		Declare("m", reflect.TypeOf(m)).
+		Declare("nm", reflect.TypeOf(m)).
+		Declare("a", reflect.TypeOf(ambiguous{})).
+		Declare("o", reflect.TypeOf(overridden{})).
+		Declare("n", reflect.TypeOf(&node{})).
+		Declare("s", reflect.TypeOf(shadowed{})).
+		Declare("h", reflect.TypeOf(h)).
+		Declare("hv", reflect.TypeOf(*h))
+	pctx := env.NewContext(context.Background())
+	ectx := goel.NewBindings().
+		Bind("m", m).
+		Bind("nm", metric{}).
+		Bind("a", ambiguous{}).
+		Bind("o", overridden{}).
+		Bind("n", &node{X: 1}).
+		Bind("s", shadowed{counter{1}, "shadow"}).
+		Bind("h", h).
+		Bind("hv", *h).
+		NewContext(context.Background())
+	runEvalTests(t, pctx, ectx, []evalTest{
+		{`m.N + m.Scale(2)`, "", 5, ""},
+		{`m.Describe()`, "", "counter 1", ""},
+		{`m.Inc() + m.Inc()`, "", 5, ""},
+		{`s.N`, "", "shadow", ""},
+		{`s.counter.N`, "1:3: cannot refer to unexported field counter of goel_test.shadowed", nil, ""},
+		{`s.Describe()`, "", "counter 1", ""},
+		{`h.Inner.Inc()`, "", 2, ""},
+		{`hv.Items[0].Inc()`, "", 6, ""},
+		{`hv.Inner.Inc()`, "1:10: cannot call pointer method Inc on goel_test.counter", nil, ""},
+		{`a.N`, "1:3: ambiguous selector N for goel_test.ambiguous", nil, ""},
+		{`a.Describe()`, "1:3: ambiguous selector Describe for goel_test.ambiguous", nil, ""},
+		{`o.Describe()`, "", "overridden", ""},
+		{`o.N`, "1:3: ambiguous selector N for goel_test.overridden", nil, ""},
+		{`n.X`, "", 1, ""},
+		{`n.Y`, "1:3: unknown selector Y for *goel_test.node", nil, ""},
+		{`m.Scale`, "", nil, ""},
+		{`nm.N`, "", nil, "1:1: nil pointer to embedded struct goel_test.counter"},
+	}, nil)
+	assert.Equal(t, 3, m.N)
+	assert.Equal(t, 2, h.Inner.N)
+	typ, _ := goel.Compile(pctx, `m.Describe`).ReturnType()
+	assert.Equal(t, reflect.TypeOf(func() string { return "" }), typ)
+}
+
+type validatorConfig struct {
+	Validator func(int) bool
+}
+
+func TestCallFunctionValues(t *testing.T) {
+	double := func(i int) int { return i * 2 }
+	makeAdder := func(n int) func(int) int { return func(i int) int { return i + n } }
+	lookup := func(name string) (func(int) int, error) {
+		if name == "double" {
+			return double, nil
+		}
+		return nil, fmt.Errorf("no function %s", name)
+	}
+	env := goel.NewEnv().
+		Declare("cfg", reflect.TypeOf(validatorConfig{})).
+		Declare("handlers", reflect.TypeOf(map[string]func(int) int{})).
+		Declare("pipeline", reflect.TypeOf([]func(int) int{})).
+		Declare("makeAdder", reflect.TypeOf(makeAdder)).
+		Declare("lookup", reflect.TypeOf(lookup)).
+		Declare("log", reflect.TypeOf(func(string) {}))
+	pctx := env.NewContext(context.Background())
+	ectx := goel.NewBindings().
+		Bind("cfg", validatorConfig{func(i int) bool { return i > 0 }}).
+		Bind("handlers", map[string]func(int) int{"double": double}).
+		Bind("pipeline", []func(int) int{double, makeAdder(1)}).
+		Bind("makeAdder", makeAdder).
+		Bind("lookup", lookup).
+		Bind("log", func(string) {}).
+		NewContext(context.Background())
+	runEvalTests(t, pctx, ectx, []evalTest{
+		{`cfg.Validator(3)`, "", true, ""},
+		{`handlers["double"](4)`, "", 8, ""},
+		{`pipeline[1](pipeline[0](2))`, "", 5, ""},
+		{`makeAdder(2)(3)`, "", 5, ""},
+		{`lookup("double")(5)`, "", 10, ""},
+		{`cfg.Validator(3, 4)`, "1:19: too many parameters to function call, expected 1, found 2", nil, ""},
+		{`handlers["double"]("x")`, "1:20: type mismatch in argument 0", nil, ""},
+		{`makeAdder(2)()`, "1:14: too few parameters to function call, expected 1, found 0", nil, ""},
+		{`log("x")`, "1:4: log has no result", nil, ""},
+		{`handlers["missing"](1)`, "", nil, "1:1: not a function"},
+		{`lookup("missing")(5)`, "", nil, "no function missing"},
+	}, nil)
+}
+
+func TestTuples(t *testing.T) {
+	divmod := func(a, b int) (int, int) { return a / b, a % b }
+	parse := func(s string) (string, int, error) {
+		if s == "" {
+			return "", 0, fmt.Errorf("empty")
+		}
+		return s, len(s), nil
+	}
+	env := goel.NewEnv().
+		Declare("divmod", reflect.TypeOf(divmod)).
+		Declare("parse", reflect.TypeOf(parse)).
+		Declare("add", reflect.TypeOf(func(a, b int) int { return 0 })).
+		Declare("repeat", reflect.TypeOf(strings.Repeat)).
+		Declare("i", goel.IntType)
+	pctx := env.NewContext(context.Background())
+	ectx := goel.NewBindings().
+		Bind("divmod", divmod).
+		Bind("parse", parse).
+		Bind("add", func(a, b int) int { return a + b }).
+		Bind("repeat", strings.Repeat).
+		Bind("i", 1).
+		NewContext(context.Background())
+	runEvalTests(t, pctx, ectx, []evalTest{
+		{`divmod(7, 2)`, "", goel.Tuple{3, 1}, ""},
+		{`divmod(7, 2)[1] + 1`, "", 2, ""},
+		{`parse("abc")[0] + "!"`, "", "abc!", ""},
+		{`add(divmod(7, 2))`, "", 4, ""},
+		{`repeat(parse("ab"))`, "", "abab", ""},
+		{`parse("")[1]`, "", nil, "empty"},
+		{`divmod(7, 2)[2]`, "1:14: tuple index out of range: 2 with 2 results", nil, ""},
+		{`divmod(7, 2)[i]`, "1:14: tuple index must be a constant", nil, ""},
+		{`divmod(7, 2)[1] + "x"`, "1:17: type mismatch in binary expression", nil, ""},
+		{`repeat(divmod(7, 2))`, "1:8: type mismatch in argument 0", nil, ""},
+		{`add(parse("ab"))`, "1:5: type mismatch in argument 0", nil, ""},
+	}, nil)
+	typ, _ := goel.Compile(pctx, `divmod(7, 2)`).ReturnType()
+	assert.Equal(t, goel.TupleType, typ)
+}
+
+func TestErrorHandling(t *testing.T) {
+	lookup := func(key string) (string, error) {
+		if key == "" {
+			return "", fmt.Errorf("no key")
+		}
+		return "value of " + key, nil
+	}
+	allowed := func(user string) (bool, error) {
+		if user == "" {
+			return false, fmt.Errorf("no user")
+		}
+		return true, nil
+	}
+	parse := func(s string) (string, int, error) {
+		if s == "" {
+			return "", 0, fmt.Errorf("empty")
+		}
+		return s, len(s), nil
+	}
+	check := func(s string) error {
+		if s == "" {
+			return fmt.Errorf("empty")
+		}
+		return nil
+	}
+	env := goel.NewEnv().
+		Declare("lookup", reflect.TypeOf(lookup)).
+		Declare("allowed", reflect.TypeOf(allowed)).
+		Declare("parse", reflect.TypeOf(parse)).
+		Declare("check", reflect.TypeOf(check)).
+		Declare("ints", reflect.TypeOf([]int{}))
+	pctx := env.NewContext(context.Background())
+	ectx := goel.NewBindings().
+		Bind("lookup", lookup).
+		Bind("allowed", allowed).
+		Bind("parse", parse).
+		Bind("check", check).
+		Bind("ints", []int{1, 2}).
+		NewContext(context.Background())
+	for _, tt := range []struct {
+		expression    string
+		opts          []goel.CompileOption
+		buildingError string
+		expected      interface{}
+		runtimeError  string
+	}{
+		{`try(lookup("a"), "none")`, nil, "", "value of a", ""},
+		{`try(lookup(""), "none")`, nil, "", "none", ""},
+		{`try(allowed(""), false) || true`, nil, "", true, ""},
+		{`try(ints[5], 0)`, nil, "", 0, ""},
+		{`try(ints[1], 0)`, nil, "", 2, ""},
+		{`try(lookup(""), 0)`, nil, "1:17: type mismatch in try, expected string but found int", nil, ""},
+		{`try(lookup(""))`, nil, "1:15: wrong number of arguments to try, expected 2, found 1", nil, ""},
+		{`lookup("")`, nil, "", nil, "no key"},
+		{`check("a")`, nil, "", nil, ""},
+		{`check("")`, nil, "", nil, "empty"},
+		{`lookup("")`, []goel.CompileOption{goel.OnError("lookup", goel.ErrorAbort, nil)}, "", nil, "no key"},
+		{`allowed("") || true`, []goel.CompileOption{goel.OnError("allowed", goel.ErrorZeroValue, nil)}, "", true, ""},
+		{`lookup("")`, []goel.CompileOption{goel.OnError("lookup", goel.ErrorZeroValue, nil)}, "", "", ""},
+		{`lookup("") + "!"`, []goel.CompileOption{goel.OnError("lookup", goel.ErrorFallback, "none")}, "", "none!", ""},
+		{`parse("")`, []goel.CompileOption{goel.OnError("parse", goel.ErrorZeroValue, nil)}, "", goel.Tuple{"", 0}, ""},
+		{`parse("")`, []goel.CompileOption{goel.OnError("parse", goel.ErrorFallback, goel.Tuple{"?", 0})}, "", goel.Tuple{"?", 0}, ""},
+		{`parse("")[0]`, []goel.CompileOption{goel.OnError("parse", goel.ErrorFallback, goel.Tuple{1, 2, 3})}, "1:6: fallback of parse has 3 values, expected 2", nil, ""},
+		{`parse("")[0]`, []goel.CompileOption{goel.OnError("parse", goel.ErrorFallback, goel.Tuple{1, 2})}, "1:6: fallback of parse result 0 has type int, expected string", nil, ""},
+		{`parse("")[0]`, []goel.CompileOption{goel.OnError("parse", goel.ErrorFallback, goel.Tuple{"?", nil})}, "1:6: fallback of parse result 1 is nil, expected int", nil, ""},
+		{`parse("")`, []goel.CompileOption{goel.OnError("parse", goel.ErrorFallback, 1)}, "1:6: fallback of parse has type int, expected goel.Tuple", nil, ""},
+		{`parse("")`, []goel.CompileOption{goel.OnError("parse", goel.ErrorFallback, nil)}, "1:6: fallback of parse has 0 values, expected 2", nil, ""},
+		{`lookup("")`, []goel.CompileOption{goel.OnError("lookup", goel.ErrorFallback, 1)}, "1:7: fallback of lookup has type int, expected string", nil, ""},
+		{`lookup("")`, []goel.CompileOption{goel.OnError("lookup", goel.ErrorFallback, nil)}, "1:7: fallback of lookup is nil, expected string", nil, ""},
+	} {
+		runEvalTest(t, pctx, ectx, evalTest{tt.expression, tt.buildingError, tt.expected, tt.runtimeError}, nil, tt.opts...)
+	}
+
+	// A call of a function only returning an error evaluates to nil, as its type says.
+	checked, err := goel.CompileAs[error](pctx, `check("a")`)
+	if assert.NoError(t, err) {
+		result, err := checked.Eval(ectx)
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+	}
+
+	// try does not recover from an exceeded budget.
+	cexp := goel.Compile(pctx, `try(lookup(""), "none")`)
+	_, err = cexp.Execute(goel.WithBudget(ectx, goel.Budget{MaxSteps: 2}))
+	assert.True(t, stderrors.Is(err, goel.BudgetExceeded), err)
+
+	// A declared identifier shadows the builtin.
+	try := func(a, b int) int { return a - b }
+	cexp = goel.Compile(goel.NewEnv().Declare("try", reflect.TypeOf(try)).NewContext(context.Background()), `try(3, 1)`)
+	if assert.NoError(t, cexp.Error()) {
+		result, err := cexp.Execute(goel.NewBindings().Bind("try", try).NewContext(context.Background()))
+		assert.NoError(t, err)
+		assert.Equal(t, 2, result)
+	}
+}
+
+func TestCompileAs(t *testing.T) {
+	env := goel.NewEnv().
+		Declare("x", goel.IntType).
+		Declare("name", goel.StringType).
+		Declare("err", goel.ErrorType)
+	pctx := env.NewContext(context.Background())
+	ectx := goel.NewBindings().Bind("x", 3).Bind("name", "goel").Bind("err", nil).NewContext(context.Background())
+
+	predicate, err := goel.CompileAs[bool](pctx, `x > 2 && name == "goel"`)
+	if assert.NoError(t, err) {
+		result, err := predicate.Eval(ectx)
+		assert.NoError(t, err)
+		assert.True(t, result)
+	}
+
+	_, err = goel.CompileAs[bool](pctx, `name + "!"`)
+	assert.EqualError(t, err, "1:1: expression of type string is not assignable to bool; did you mean name + \"!\" == \"...\"?")
+	assert.True(t, stderrors.Is(err, goel.TypeMismatch))
+
+	_, err = goel.CompileAs[bool](pctx, `x >`)
+	assert.True(t, stderrors.Is(err, goel.SyntaxError))
+
+	_, err = goel.CompileAs[float64](pctx, `x * 2`)
+	assert.EqualError(t, err, "1:1: expression of type int is not assignable to float64")
+	scaled, err := goel.CompileAs[float64](pctx, `x * 2`, goel.ConvertNumericResult())
+	if assert.NoError(t, err) {
+		result, err := scaled.Eval(ectx)
+		assert.NoError(t, err)
+		assert.Equal(t, 6.0, result)
+	}
+	_, err = goel.CompileAs[float64](pctx, `name`, goel.ConvertNumericResult())
+	assert.EqualError(t, err, "1:1: expression of type string is not assignable to float64")
+	_, err = goel.CompileAs[int](pctx, `nil`, goel.ConvertNumericResult())
+	assert.EqualError(t, err, "1:1: expression of type <nil> is not assignable to int")
+	assert.True(t, stderrors.Is(err, goel.TypeMismatch))
+
+	value, err := goel.CompileAs[interface{}](pctx, `name`)
+	if assert.NoError(t, err) {
+		result, err := value.Eval(ectx)
+		assert.NoError(t, err)
+		assert.Equal(t, "goel", result)
+	}
+
+	nilErr, err := goel.CompileAs[error](pctx, `err`)
+	if assert.NoError(t, err) {
+		result, err := nilErr.Eval(ectx)
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+	}
+
+	_, err = predicate.Eval(goel.NewBindings().Bind("x", 3).NewContext(context.Background()))
+	assert.EqualError(t, err, "1:10: undefined identifier: name")
+}
+
+func TestExpectType(t *testing.T) {
+	env := goel.NewEnv().
+		Declare("x", goel.IntType).
+		Declare("name", goel.StringType).
+		Declare("tags", reflect.TypeOf([]string{})).
+		Declare("v", goel.InterfaceType).
+		Declare("err", goel.ErrorType)
+	pctx := env.NewContext(context.Background())
+	for _, tt := range []struct {
+		expression    string
+		typ           reflect.Type
+		buildingError string
+	}{
+		{`x > 2`, goel.BoolType, ""},
+		{`name`, goel.StringType, ""},
+		{`err`, goel.ErrorType, ""},
+		{`nil`, goel.ErrorType, ""},
+		{`name`, goel.InterfaceType, ""},
+		{`name`, goel.BoolType, "1:1: expression of type string is not assignable to bool; did you mean name == \"...\"?"},
+		{`x + 1`, goel.BoolType, "1:1: expression of type int is not assignable to bool; did you mean x + 1 != 0?"},
+		{`tags`, goel.BoolType, "1:1: expression of type []string is not assignable to bool"},
+		{` -x`, goel.BoolType, "1:2: expression of type int is not assignable to bool; did you mean -x != 0?"},
+		{`  v.(string)`, goel.BoolType, "1:3: expression of type string is not assignable to bool; did you mean v.(string) == \"...\"?"},
+		{`x`, goel.StringType, "1:1: expression of type int is not assignable to string"},
+		{`x`, goel.DoubleType, "1:1: expression of type int is not assignable to float64"},
+		{`  (name)`, goel.BoolType, "1:4: expression of type string is not assignable to bool; did you mean name == \"...\"?"},
+		{`y`, goel.BoolType, "1:1: unknown identifier: y"},
+	} {
+		cexp := goel.Compile(pctx, tt.expression, goel.ExpectType(tt.typ))
+		if tt.buildingError == "" {
+			assert.NoError(t, cexp.Error(), tt.expression)
+			continue
+		}
+		assert.EqualError(t, cexp.Error(), tt.buildingError, tt.expression)
+	}
+	err := goel.Compile(pctx, `name`, goel.ExpectType(goel.BoolType)).Error()
+	assert.True(t, stderrors.Is(err, goel.TypeMismatch))
+}
+
+// evalTest is a case of the table driven tests run by runEvalTest.  The result is only compared to expected if it is
+// not nil.
+type evalTest struct {
+	expression    string
+	buildingError string
+	expected      interface{}
+	runtimeError  string
+}
+
+// runEvalTest compiles the expression of tt with opts and executes it with ectx unless building it is expected to
+// fail.  The expected errors must match kind unless it is nil.
+func runEvalTest(t *testing.T, pctx, ectx context.Context, tt evalTest, kind error, opts ...goel.CompileOption) {
+	cexp := goel.Compile(pctx, tt.expression, opts...)
+	if tt.buildingError != "" {
+		assert.EqualError(t, cexp.Error(), tt.buildingError, tt.expression)
+		if kind != nil {
+			assert.True(t, stderrors.Is(cexp.Error(), kind), tt.expression)
+		}
+		return
+	}
+	if !assert.NoError(t, cexp.Error(), tt.expression) {
+		return
+	}
+	result, err := cexp.Execute(ectx)
+	if tt.runtimeError != "" {
+		assert.EqualError(t, err, tt.runtimeError, tt.expression)
+		if kind != nil {
+			assert.True(t, stderrors.Is(err, kind), tt.expression)
+		}
+		return
+	}
+	if assert.NoError(t, err, tt.expression) && tt.expected != nil {
+		assert.Equal(t, tt.expected, result, tt.expression)
+	}
+}
+
+// runEvalTests runs each of tests with runEvalTest.
+func runEvalTests(t *testing.T, pctx, ectx context.Context, tests []evalTest, kind error, opts ...goel.CompileOption) {
+	for _, tt := range tests {
+		runEvalTest(t, pctx, ectx, tt, kind, opts...)
+	}
+}
+
 func contextFromMap(contextMap map[string]interface{}) context.Context {
 	pctx := context.Background()
 	for k, v := range contextMap {
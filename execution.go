@@ -0,0 +1,103 @@
+package goel
+
+import (
+	"context"
+	"go/token"
+	"reflect"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// checkInterval is the number of steps of an execution between two checks for the cancellation of its context.
+const checkInterval = 64
+
+// executionState is the state of a single execution of an expression, shared by its nodes through the execution
+// context.
+type executionState struct {
+	budget   Budget
+	steps    int
+	calls    int
+	bytes    int
+	resolved map[*resolver]resolvedValue
+}
+
+// resolvedValue is the result of calling a resolver.
+type resolvedValue struct {
+	value reflect.Value
+	err   error
+	// panicked reports whether err is the *RuntimeError reporting a panic of the resolver.
+	panicked bool
+}
+
+type executionStateKey struct{}
+
+// withExecutionState returns a copy of ectx carrying a new execution state limited by the Budget of ectx, if any.
+func withExecutionState(ectx context.Context) context.Context {
+	state := &executionState{}
+	if budget, ok := ectx.Value(budgetKey{}).(Budget); ok {
+		state.budget = budget
+	}
+	return context.WithValue(ectx, executionStateKey{}, state)
+}
+
+// executionStateFrom returns the execution state of ectx or nil if it has none.
+func executionStateFrom(ectx context.Context) *executionState {
+	state, _ := ectx.Value(executionStateKey{}).(*executionState)
+	return state
+}
+
+// resolve calls the resolver with call unless it was already called during the execution ectx belongs to.  call
+// applies the panic policy and only returns an error if the resolver panicked.
+func (r *resolver) resolve(ectx context.Context, call func(ectx context.Context, fn reflect.Value, args []reflect.Value) ([]reflect.Value, error)) resolvedValue {
+	state := executionStateFrom(ectx)
+	if state != nil {
+		if rv, ok := state.resolved[r]; ok {
+			return rv
+		}
+	}
+	var rv resolvedValue
+	results, err := call(ectx, r.fn, []reflect.Value{reflect.ValueOf(ectx)})
+	if err != nil {
+		rv = resolvedValue{err: err, panicked: true}
+	} else {
+		rv.value = results[0]
+		if rv.value.Kind() == reflect.Interface {
+			rv.value = rv.value.Elem()
+		}
+		if !results[1].IsNil() {
+			rv.err = results[1].Interface().(error)
+		}
+	}
+	if state != nil {
+		if state.resolved == nil {
+			state.resolved = make(map[*resolver]resolvedValue)
+		}
+		state.resolved[r] = rv
+	}
+	return rv
+}
+
+// checkpoint counts a step of the execution ectx belongs to against its budget and, every checkInterval steps,
+// reports whether ectx was canceled.
+func checkpoint(ectx context.Context, pos token.Pos) error {
+	state := executionStateFrom(ectx)
+	if state == nil {
+		return nil
+	}
+	state.steps++
+	if state.budget.MaxSteps > 0 && state.steps > state.budget.MaxSteps {
+		return budgetExceeded(pos, StepLimit, state.budget.MaxSteps)
+	}
+	if state.steps%checkInterval != 0 {
+		return nil
+	}
+	return checkCanceled(ectx, pos)
+}
+
+// checkCanceled returns an error wrapping ectx.Err() at pos if ectx was canceled or its deadline exceeded.
+func checkCanceled(ectx context.Context, pos token.Pos) error {
+	if err := ectx.Err(); err != nil {
+		return &RuntimeError{Kind: Canceled, Pos: pos, Msg: err.Error(), Err: err}
+	}
+	return nil
+}
@@ -2,7 +2,7 @@ package goel
 
 import (
 	"context"
-	"github.com/pkg/errors"
+	"fmt"
 	"go/ast"
 	"reflect"
 )
@@ -11,6 +11,10 @@ type lookUpIdentifierValueCompiledExpression struct {
 	nopExpression
 	exp *ast.Ident
 	typ reflect.Type
+	// size is the size hint declared for the identifier or -1 if there is none.
+	size        int
+	panicPolicy PanicPolicy
+	panicHook   PanicHook
 }
 
 func (luivce *lookUpIdentifierValueCompiledExpression) ReturnType() (reflect.Type, error) {
@@ -18,15 +22,45 @@ func (luivce *lookUpIdentifierValueCompiledExpression) ReturnType() (reflect.Typ
 }
 
 func (luivce *lookUpIdentifierValueCompiledExpression) Execute(ectx context.Context) (interface{}, error) {
-	_v := ectx.Value(luivce.exp.Name)
+	if err := checkpoint(ectx, luivce.exp.NamePos); err != nil {
+		return nil, err
+	}
+	_v := lookupBinding(ectx, luivce.exp.Name)
 	if _v == nil {
-		return nil, errors.Errorf("%d: undefined identifier: %s", luivce.exp.NamePos, luivce.exp.Name)
+		return nil, runtimeErrorf(luivce.exp.NamePos, UnboundIdentifier, "undefined identifier: %s", luivce.exp.Name)
+	}
+	if r, ok := _v.(*resolver); ok {
+		rv := r.resolve(ectx, luivce.callResolver)
+		if rv.panicked {
+			return nil, rv.err
+		}
+		if rv.err != nil {
+			msg := fmt.Sprintf("cannot resolve %s: %v", luivce.exp.Name, rv.err)
+			return nil, &RuntimeError{Kind: ResolverError, Pos: luivce.exp.NamePos, Msg: msg, Err: rv.err}
+		}
+		_v = rv.value
 	}
 	v, ok := _v.(reflect.Value)
 	if ok && v.IsValid() && v.Type().AssignableTo(luivce.typ) {
 		return v.Interface(), nil
 	}
-	return nil, errors.Errorf("%d: value type mismatch: %s with type %v", luivce.exp.NamePos, luivce.exp.Name, v)
+	if ok && !v.IsValid() && isNillable(luivce.typ) {
+		return reflect.Zero(luivce.typ).Interface(), nil
+	}
+	return nil, runtimeErrorf(luivce.exp.NamePos, TypeMismatch, "value type mismatch: %s with type %v", luivce.exp.Name, v)
+}
+
+// callResolver calls the resolver function fn of the identifier applying the panic policy.
+func (luivce *lookUpIdentifierValueCompiledExpression) callResolver(ectx context.Context, fn reflect.Value, args []reflect.Value) ([]reflect.Value, error) {
+	return callWithPanicPolicy(ectx, luivce.panicPolicy, luivce.panicHook, luivce.exp.Name, luivce.exp.NamePos, fn, args)
+}
+
+func isNillable(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Map, reflect.Ptr, reflect.UnsafePointer, reflect.Interface, reflect.Slice:
+		return true
+	}
+	return false
 }
 
 // Map of global constants that are defined in go.
@@ -40,13 +74,19 @@ func evalIdentifierExpr(pctx context.Context, exp *ast.Ident) compiledExpression
 	if v, ok := literalIdentifiers[exp.Name]; ok {
 		return literal(exp, v, reflect.TypeOf(v))
 	}
-	_vtype := pctx.Value(exp.Name)
+	_vtype := lookupDeclaration(pctx, exp.Name)
 	if _vtype != nil {
 		vtype, ok := _vtype.(reflect.Type)
 		if !ok {
-			return newErrorExpression(errors.Errorf("%d: identifier type is not a reflect.Type: %s(%T)", exp.NamePos, exp.Name, vtype))
+			return newErrorExpression(compileErrorf(exp.NamePos, TypeMismatch, "identifier type is not a reflect.Type: %s(%T)", exp.Name, _vtype))
+		}
+		options := compileOptionsFrom(pctx)
+		options.identifiers = append(options.identifiers, exp)
+		size, ok := lookupSizeHint(pctx, exp.Name)
+		if !ok {
+			size = -1
 		}
-		return &lookUpIdentifierValueCompiledExpression{nopExpression{exp}, exp, vtype}
+		return &lookUpIdentifierValueCompiledExpression{nopExpression{exp}, exp, vtype, size, options.panicPolicy, options.panicHook}
 	}
-	return newErrorExpression(errors.Errorf("%d: unknown identifier: %s", exp.NamePos, exp.Name))
+	return newErrorExpression(compileErrorf(exp.NamePos, UndefinedIdentifier, "unknown identifier: %s", exp.Name))
 }
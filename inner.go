@@ -2,48 +2,60 @@ package goel
 
 import (
 	"context"
-	"github.com/pkg/errors"
 	"go/ast"
 	"reflect"
 )
 
 type innerCompiledExpression struct {
 	nopExpression
-	exp  *ast.IndexExpr
-	xexp CompiledExpression
-	iexp CompiledExpression
-	xtyp reflect.Type
-	ktyp reflect.Type
-	etyp reflect.Type
+	exp   *ast.IndexExpr
+	xexp  CompiledExpression
+	iexp  CompiledExpression
+	xtyp  reflect.Type
+	ktyp  reflect.Type
+	etyp  reflect.Type
+	isPtr bool
+	// takeAddr is set when the expression evaluates to the address of the element rather than its value.
+	takeAddr bool
 }
 
 func (ice *innerCompiledExpression) ReturnType() (reflect.Type, error) {
 	return ice.etyp, nil
 }
 
-func (ice *innerCompiledExpression) Execute(ectx context.Context) (interface{}, error) {
+func (ice *innerCompiledExpression) Execute(ectx context.Context) (result interface{}, err error) {
+	defer recoverRuntimePanic(ice.exp.Lbrack, &err)
+	if err := checkpoint(ectx, ice.exp.Lbrack); err != nil {
+		return nil, err
+	}
 	x, err := ice.xexp.Execute(ectx)
 	if err != nil {
 		return nil, err
 	}
 	if x == nil {
-		return nil, errors.Errorf("%d: expression evaluates to nil", ice.exp.X.Pos())
+		return nil, runtimeErrorf(ice.exp.X.Pos(), NilDereference, "expression evaluates to nil")
+	}
+	xx := reflect.ValueOf(x)
+	if ice.isPtr {
+		if xx.IsNil() {
+			return nil, runtimeErrorf(ice.exp.X.Pos(), NilDereference, "nil pointer dereference")
+		}
+		xx = xx.Elem()
 	}
-	if xxtyp := reflect.TypeOf(x); !xxtyp.AssignableTo(ice.xtyp) {
-		return nil, errors.Errorf("%d: expression evaluated to incorrect type. expected %s found %s", ice.exp.X.Pos(), ice.xtyp.Name(), xxtyp.Name())
+	if xxtyp := xx.Type(); !xxtyp.AssignableTo(ice.xtyp) {
+		return nil, runtimeErrorf(ice.exp.X.Pos(), TypeMismatch, "expression evaluated to incorrect type. expected %s found %s", ice.xtyp.Name(), xxtyp.Name())
 	}
 	i, err := ice.iexp.Execute(ectx)
 	if err != nil {
 		return nil, err
 	}
 	if i == nil {
-		return nil, errors.Errorf("%d: expression evaluates to nil", ice.exp.Index.Pos())
+		return nil, runtimeErrorf(ice.exp.Index.Pos(), NilDereference, "expression evaluates to nil")
 	}
 	if iityp := reflect.TypeOf(i); !iityp.AssignableTo(ice.ktyp) {
-		return nil, errors.Errorf("%d: expression evaluated to incorrect type. expected %s found %s", ice.exp.Index.Pos(), ice.ktyp.Name(), iityp.Name())
+		return nil, runtimeErrorf(ice.exp.Index.Pos(), TypeMismatch, "expression evaluated to incorrect type. expected %s found %s", ice.ktyp.Name(), iityp.Name())
 	}
 	var vv reflect.Value
-	xx := reflect.ValueOf(x)
 	if ice.xtyp.Kind() == reflect.Map {
 		vv = xx.MapIndex(reflect.ValueOf(i))
 		zero := reflect.Zero(ice.etyp)
@@ -63,45 +75,66 @@ func (ice *innerCompiledExpression) Execute(ectx context.Context) (interface{},
 	} else {
 		idx, ok := i.(int)
 		if !ok {
-			return nil, errors.Errorf("%d: result of expression is not an int.", ice.exp.Index.Pos())
+			return nil, runtimeErrorf(ice.exp.Index.Pos(), TypeMismatch, "result of expression is not an int.")
+		}
+		if idx < 0 {
+			return nil, runtimeErrorf(ice.exp.Index.Pos(), IndexOutOfRange, "index out of bounds, index = %d must not be negative", idx)
 		}
 		if idx >= xx.Len() {
-			return nil, errors.Errorf("%d: index out of bounds, len = %d index = %d", ice.exp.Index.Pos(), xx.Len(), idx)
+			return nil, runtimeErrorf(ice.exp.Index.Pos(), IndexOutOfRange, "index out of bounds, len = %d index = %d", xx.Len(), idx)
 		}
 		vv = xx.Index(idx)
 	}
+	if ice.takeAddr {
+		return vv.Addr().Interface(), nil
+	}
 	v := vv.Interface()
 	return v, nil
 }
 
+// addr returns an expression evaluating to the address of the element if it is an element of a slice or of an array
+// reached through a pointer.
+func (ice *innerCompiledExpression) addr() compiledExpression {
+	if ice.xtyp.Kind() != reflect.Slice && (ice.xtyp.Kind() != reflect.Array || !ice.isPtr) {
+		return nil
+	}
+	c := *ice
+	c.etyp, c.takeAddr = reflect.PtrTo(c.etyp), true
+	return &c
+}
+
 func evalInnerExpr(pctx context.Context, exp *ast.IndexExpr) compiledExpression {
 	xexp := compile(pctx, exp.X)
-	if xexp.Error() != nil {
-		return xexp
+	iexp := compile(pctx, exp.Index)
+	if failed := collectErrors(pctx, xexp, iexp); failed != nil {
+		return failed
+	}
+	if resultTypes := tupleResultTypes(xexp); resultTypes != nil {
+		return evalTupleIndexExpr(exp, xexp, iexp, resultTypes)
 	}
 	xtyp, _ := xexp.ReturnType()
 	isPtr := xtyp.Kind() == reflect.Ptr
 	if isPtr {
 		xtyp = xtyp.Elem()
 	}
-	iexp := compile(pctx, exp.Index)
-	if iexp.Error() != nil {
-		return iexp
-	}
 	ityp, _ := iexp.ReturnType()
-	etyp := xtyp.Elem()
 
-	var ktyp reflect.Type
+	var ktyp, etyp reflect.Type
 	if xtyp.Kind() == reflect.Map {
 		ktyp = xtyp.Key()
-	} else if xtyp.Kind() == reflect.Array || xtyp.Kind() == reflect.Slice || xtyp.Kind() == reflect.String {
+		etyp = xtyp.Elem()
+	} else if xtyp.Kind() == reflect.Array || xtyp.Kind() == reflect.Slice {
+		ktyp = IntType
+		etyp = xtyp.Elem()
+	} else if xtyp.Kind() == reflect.String {
 		ktyp = IntType
+		etyp = builtinTypeIdentifiers["byte"]
 	} else {
-		return newErrorExpression(errors.Errorf("%d: not an index type %s", exp.X.Pos(), xtyp.Name()))
+		return newErrorExpression(compileErrorf(exp.X.Pos(), UnsupportedExpression, "not an index type %s", xtyp.Name()))
 	}
 	if !ityp.AssignableTo(ktyp) {
-		return newErrorExpression(errors.Errorf("%d: incorrect index type. expected %s, found %s", exp.Index.Pos(), ktyp.Name(), ityp.Name()))
+		return newErrorExpression(compileErrorf(exp.Index.Pos(), TypeMismatch, "incorrect index type. expected %s, found %s", ktyp.Name(), ityp.Name()))
 	}
-	return &innerCompiledExpression{nopExpression{exp}, exp, xexp, iexp, xtyp, ktyp, etyp}
+	return &innerCompiledExpression{nopExpression{exp}, exp, xexp, iexp, xtyp, ktyp, etyp, isPtr, false}
 
 }
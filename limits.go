@@ -0,0 +1,88 @@
+package goel
+
+import (
+	"context"
+	"go/ast"
+)
+
+// MaxNodes limits the number of nodes of the syntax tree of an expression, parentheses included.
+func MaxNodes(n int) CompileOption {
+	return func(opts *compileOptions) {
+		opts.maxNodes = n
+	}
+}
+
+// MaxDepth limits how deeply the nodes of the syntax tree of an expression, parentheses included, may be nested.
+func MaxDepth(n int) CompileOption {
+	return func(opts *compileOptions) {
+		opts.maxDepth = n
+	}
+}
+
+// MaxLiteralLength limits the length in bytes of the source of each literal of an expression.
+func MaxLiteralLength(n int) CompileOption {
+	return func(opts *compileOptions) {
+		opts.maxLiteralLength = n
+	}
+}
+
+// MaxIdentifiers limits the number of distinct identifiers an expression may use.
+func MaxIdentifiers(n int) CompileOption {
+	return func(opts *compileOptions) {
+		opts.maxIdentifiers = n
+	}
+}
+
+// limitExceeded is the value compile panics with to abandon an expression that exceeds a limit.
+type limitExceeded struct {
+	err error
+}
+
+// enter accounts for compiling exp and panics with a limitExceeded if it exceeds a limit.
+func (opts *compileOptions) enter(exp ast.Expr) {
+	opts.nodes++
+	opts.depth++
+	switch {
+	case opts.maxNodes > 0 && opts.nodes > opts.maxNodes:
+		panic(limitExceeded{compileErrorf(exp.Pos(), LimitExceeded, "expression has more than %d nodes", opts.maxNodes)})
+	case opts.maxDepth > 0 && opts.depth > opts.maxDepth:
+		panic(limitExceeded{compileErrorf(exp.Pos(), LimitExceeded, "expression is nested more than %d levels deep", opts.maxDepth)})
+	}
+	switch exp := exp.(type) {
+	case *ast.BasicLit:
+		if opts.maxLiteralLength > 0 && len(exp.Value) > opts.maxLiteralLength {
+			panic(limitExceeded{compileErrorf(exp.Pos(), LimitExceeded, "literal is longer than %d bytes", opts.maxLiteralLength)})
+		}
+	case *ast.Ident:
+		if _, ok := literalIdentifiers[exp.Name]; ok || opts.maxIdentifiers <= 0 {
+			break
+		}
+		if opts.identifierNames == nil {
+			opts.identifierNames = make(map[string]bool)
+		}
+		opts.identifierNames[exp.Name] = true
+		if len(opts.identifierNames) > opts.maxIdentifiers {
+			panic(limitExceeded{compileErrorf(exp.Pos(), LimitExceeded, "expression uses more than %d identifiers", opts.maxIdentifiers)})
+		}
+	}
+}
+
+// leave accounts for having compiled an expression entered.
+func (opts *compileOptions) leave() {
+	opts.depth--
+}
+
+// compileWithinLimits compiles exp unless it exceeds a limit, in which case compiling stops at once, even with the
+// AllErrors option, and the error is reported.
+func compileWithinLimits(pctx context.Context, exp ast.Expr) (cexp compiledExpression) {
+	defer func() {
+		if r := recover(); r != nil {
+			le, ok := r.(limitExceeded)
+			if !ok {
+				panic(r)
+			}
+			cexp = newErrorExpression(le.err)
+		}
+	}()
+	return compile(pctx, exp)
+}
@@ -3,7 +3,6 @@ package goel
 import (
 	"context"
 	"fmt"
-	"github.com/pkg/errors"
 	"go/ast"
 	"go/token"
 	"reflect"
@@ -44,6 +43,6 @@ func evalLiteralExpr(ctx context.Context, exp *ast.BasicLit) compiledExpression
 	case token.STRING, token.CHAR:
 		return literal(exp, exp.Value[1:len(exp.Value)-1], StringType)
 	default:
-		return newErrorExpression(errors.Errorf("%d: unknown literal type: %s with value %s", exp.Pos(), exp.Kind, exp.Value))
+		return newErrorExpression(compileErrorf(exp.Pos(), UnsupportedExpression, "unknown literal type: %s with value %s", exp.Kind, exp.Value))
 	}
 }
@@ -0,0 +1,204 @@
+package goel
+
+import (
+	"context"
+	"go/token"
+	"reflect"
+)
+
+var (
+	// Int64Type is a reflect.Type for int64
+	Int64Type = reflect.TypeOf(int64(0))
+	// Uint64Type is a reflect.Type for uint64
+	Uint64Type = reflect.TypeOf(uint64(0))
+	// ComplexType is a reflect.Type for complex128
+	ComplexType = reflect.TypeOf(complex128(0))
+)
+
+type conversionCompiledExpression struct {
+	nopExpression
+	xexp compiledExpression
+	typ  reflect.Type
+	pos  token.Pos
+}
+
+func (cce *conversionCompiledExpression) Pos() token.Pos {
+	return cce.pos
+}
+
+func (cce *conversionCompiledExpression) ReturnType() (reflect.Type, error) {
+	return cce.typ, nil
+}
+
+func (cce *conversionCompiledExpression) Execute(ectx context.Context) (interface{}, error) {
+	x, err := cce.xexp.Execute(ectx)
+	if err != nil {
+		return nil, err
+	}
+	xv := reflect.ValueOf(x)
+	if xv.IsValid() && isComplex(cce.typ) && !isComplex(xv.Type()) && xv.Type().ConvertibleTo(DoubleType) {
+		// reflect does not convert between real and complex numbers.
+		xv = reflect.ValueOf(complex(xv.Convert(DoubleType).Float(), 0))
+	}
+	if !xv.IsValid() || !xv.Type().ConvertibleTo(cce.typ) {
+		return nil, runtimeErrorf(cce.pos, TypeMismatch, "cannot convert %T to %s", x, cce.typ.Name())
+	}
+	return xv.Convert(cce.typ).Interface(), nil
+}
+
+func convert(xexp compiledExpression, pos token.Pos, typ reflect.Type) compiledExpression {
+	if xtyp, _ := xexp.ReturnType(); xtyp == typ {
+		return xexp
+	}
+	return &conversionCompiledExpression{nopExpression{}, xexp, typ, pos}
+}
+
+func isSignedInteger(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUnsignedInteger(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func isFloat(t reflect.Type) bool {
+	return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+}
+
+func isComplex(t reflect.Type) bool {
+	return t.Kind() == reflect.Complex64 || t.Kind() == reflect.Complex128
+}
+
+func isNumeric(t reflect.Type) bool {
+	return isSignedInteger(t) || isUnsignedInteger(t) || isFloat(t) || isComplex(t)
+}
+
+// promotedNumericType returns the type both operands of a binary expression are converted to when lenient numerics
+// are enabled or nil if the operands are not both numeric.  The rules are:
+//   - if either operand is complex, both are converted to complex128.
+//   - if either operand is a float, both are converted to float64.
+//   - if both operands are int, no conversion takes place.
+//   - if both operands are signed integers, both are converted to int64.
+//   - if both operands are unsigned integers, both are converted to uint64.
+//   - if one operand is signed and the other is an unsigned integer of 32 bits or less, both are converted to int64.
+//   - otherwise, a signed and a 64 bit unsigned integer are converted to float64.
+func promotedNumericType(lt, rt reflect.Type) reflect.Type {
+	switch {
+	case !isNumeric(lt) || !isNumeric(rt):
+		return nil
+	case isComplex(lt) || isComplex(rt):
+		return ComplexType
+	case isFloat(lt) || isFloat(rt):
+		return DoubleType
+	case lt == IntType && rt == IntType:
+		return IntType
+	case isSignedInteger(lt) && isSignedInteger(rt):
+		return Int64Type
+	case isUnsignedInteger(lt) && isUnsignedInteger(rt):
+		return Uint64Type
+	}
+	unsigned := lt
+	if isSignedInteger(lt) {
+		unsigned = rt
+	}
+	if unsigned.Size() < 8 {
+		return Int64Type
+	}
+	return DoubleType
+}
+
+func addint64(l, r interface{}) interface{} {
+	return l.(int64) + r.(int64)
+}
+
+func adduint64(l, r interface{}) interface{} {
+	return l.(uint64) + r.(uint64)
+}
+
+func addcomplex(l, r interface{}) interface{} {
+	return l.(complex128) + r.(complex128)
+}
+
+func subint64(l, r interface{}) interface{} {
+	return l.(int64) - r.(int64)
+}
+
+func subuint64(l, r interface{}) interface{} {
+	return l.(uint64) - r.(uint64)
+}
+
+func subcomplex(l, r interface{}) interface{} {
+	return l.(complex128) - r.(complex128)
+}
+
+func mulint64(l, r interface{}) interface{} {
+	return l.(int64) * r.(int64)
+}
+
+func muluint64(l, r interface{}) interface{} {
+	return l.(uint64) * r.(uint64)
+}
+
+func mulcomplex(l, r interface{}) interface{} {
+	return l.(complex128) * r.(complex128)
+}
+
+func divint64(l, r interface{}) interface{} {
+	return l.(int64) / r.(int64)
+}
+
+func divuint64(l, r interface{}) interface{} {
+	return l.(uint64) / r.(uint64)
+}
+
+func divcomplex(l, r interface{}) interface{} {
+	return l.(complex128) / r.(complex128)
+}
+
+func modint64(l, r interface{}) interface{} {
+	return l.(int64) % r.(int64)
+}
+
+func moduint64(l, r interface{}) interface{} {
+	return l.(uint64) % r.(uint64)
+}
+
+func gtrint64(l, r interface{}) interface{} {
+	return l.(int64) > r.(int64)
+}
+
+func gtruint64(l, r interface{}) interface{} {
+	return l.(uint64) > r.(uint64)
+}
+
+func geqint64(l, r interface{}) interface{} {
+	return l.(int64) >= r.(int64)
+}
+
+func gequint64(l, r interface{}) interface{} {
+	return l.(uint64) >= r.(uint64)
+}
+
+func lssint64(l, r interface{}) interface{} {
+	return l.(int64) < r.(int64)
+}
+
+func lssuint64(l, r interface{}) interface{} {
+	return l.(uint64) < r.(uint64)
+}
+
+func leqint64(l, r interface{}) interface{} {
+	return l.(int64) <= r.(int64)
+}
+
+func lequint64(l, r interface{}) interface{} {
+	return l.(uint64) <= r.(uint64)
+}
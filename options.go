@@ -0,0 +1,97 @@
+package goel
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"reflect"
+)
+
+// CompileOption configures how an expression is compiled.
+type CompileOption func(*compileOptions)
+
+type compileOptions struct {
+	lenientNumerics   bool
+	checkedArithmetic bool
+	panicPolicy       PanicPolicy
+	panicHook         PanicHook
+	fset              *token.FileSet
+	src               string
+	allErrors         bool
+	maxNodes          int
+	maxDepth          int
+	maxLiteralLength  int
+	maxIdentifiers    int
+	policy            *Policy
+	pure              *PureRegistry
+	allowUnexported   bool
+	errorHandlers     map[string]errorHandler
+	convertNumeric    bool
+	expectType        reflect.Type
+
+	// identifiers collects the identifiers looked up in the parsing context while compiling.
+	identifiers []*ast.Ident
+	// nodes, depth and identifierNames account for the limits while compiling.
+	nodes, depth    int
+	identifierNames map[string]bool
+}
+
+type compileOptionsKey struct{}
+
+// LenientNumerics enables the promotion of mismatched numeric operands in binary expressions to a common type.
+// See the README for the promotion rules.  Without this option, both operands must have the same type as in go.
+func LenientNumerics() CompileOption {
+	return func(opts *compileOptions) {
+		opts.lenientNumerics = true
+	}
+}
+
+// CheckedArithmetic makes integer addition, subtraction, multiplication, division and negation report an error when
+// the result overflows instead of wrapping around as it does in go.
+func CheckedArithmetic() CompileOption {
+	return func(opts *compileOptions) {
+		opts.checkedArithmetic = true
+	}
+}
+
+// FileSet provides the file set the expression was parsed with so that errors report the line and column of their
+// position instead of the raw token.Pos.  If src is not empty, it is the parsed source and errors include the line of
+// source containing their position as a snippet.
+func FileSet(fset *token.FileSet, src string) CompileOption {
+	return func(opts *compileOptions) {
+		opts.fset = fset
+		opts.src = src
+	}
+}
+
+// AllowUnexported allows the expression to read unexported fields, e.g. to inspect values in tests.  The fields are
+// read through a copy of the struct when it is not addressable.  Unexported methods can not be called even with this
+// option.
+func AllowUnexported() CompileOption {
+	return func(opts *compileOptions) {
+		opts.allowUnexported = true
+	}
+}
+
+// AllErrors continues compiling the rest of the expression after an error so that all of the errors are reported at
+// once.  The Error method of the compiled expression then returns an ErrorList.
+func AllErrors() CompileOption {
+	return func(opts *compileOptions) {
+		opts.allErrors = true
+	}
+}
+
+func withCompileOptions(pctx context.Context, opts []CompileOption) context.Context {
+	options := &compileOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return context.WithValue(pctx, compileOptionsKey{}, options)
+}
+
+func compileOptionsFrom(pctx context.Context) *compileOptions {
+	if options, ok := pctx.Value(compileOptionsKey{}).(*compileOptions); ok {
+		return options
+	}
+	return &compileOptions{}
+}
@@ -0,0 +1,106 @@
+package goel
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"reflect"
+	"runtime/debug"
+)
+
+// PanicPolicy determines what happens when a function called by an expression, or the resolver of an identifier bound
+// with BindLazy, panics.
+type PanicPolicy int
+
+const (
+	// PanicRecover recovers the panic and returns it from Execute as a *RuntimeError caused by a *PanicError.  This is
+	// the default.
+	PanicRecover PanicPolicy = iota
+	// PanicPropagate lets the panic unwind through Execute into the caller.
+	PanicPropagate
+	// PanicRecoverAndLog recovers the panic like PanicRecover but first passes the *PanicError to a PanicHook.
+	PanicRecoverAndLog
+)
+
+// PanicHook is called with the execution context and the recovered panic when the PanicRecoverAndLog policy is in
+// effect.
+type PanicHook func(executionContext context.Context, err *PanicError)
+
+// PanicError is the cause of the *RuntimeError of kind Panic returned by Execute when a function called by the
+// expression panics.
+type PanicError struct {
+	// Func is the function expression as it appears in the source, e.g. "req.Header.Get".
+	Func string
+	// Pos is the position of the call.
+	Pos token.Pos
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v", pe.Func, pe.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (pe *PanicError) Unwrap() error {
+	if err, ok := pe.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// OnPanic sets the policy applied when a function called by the expression panics.  The hook is only used with the
+// PanicRecoverAndLog policy.
+func OnPanic(policy PanicPolicy, hook PanicHook) CompileOption {
+	return func(opts *compileOptions) {
+		opts.panicPolicy = policy
+		opts.panicHook = hook
+	}
+}
+
+// propagatedPanic carries the value of a panic that the PanicPropagate policy lets unwind through Execute so that the
+// expressions enclosing the call do not recover it like the faults they raise themselves.
+type propagatedPanic struct {
+	value interface{}
+}
+
+// repanicPropagated must be deferred by Execute of the root of the expression.  It lets a panic propagated from a
+// function unwind into the caller with its original value.
+func repanicPropagated() {
+	if r := recover(); r != nil {
+		if pp, ok := r.(*propagatedPanic); ok {
+			panic(pp.value)
+		}
+		panic(r)
+	}
+}
+
+// callFunction calls fn with args applying the panic policy.
+func (cce *callCompiledExpression) callFunction(ectx context.Context, fn reflect.Value, args []reflect.Value) ([]reflect.Value, error) {
+	return callWithPanicPolicy(ectx, cce.panicPolicy, cce.panicHook, cce.name, cce.exp.Lparen, fn, args)
+}
+
+// callWithPanicPolicy calls fn with args applying policy.  A recovered panic is reported as raised by the function
+// expression name at pos.
+func callWithPanicPolicy(ectx context.Context, policy PanicPolicy, hook PanicHook, name string, pos token.Pos, fn reflect.Value, args []reflect.Value) (results []reflect.Value, err error) {
+	if policy == PanicPropagate {
+		defer func() {
+			if r := recover(); r != nil {
+				panic(&propagatedPanic{r})
+			}
+		}()
+		return fn.Call(args), nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			pe := &PanicError{name, pos, r, debug.Stack()}
+			if policy == PanicRecoverAndLog && hook != nil {
+				hook(ectx, pe)
+			}
+			err = &RuntimeError{Kind: Panic, Pos: pe.Pos, Msg: pe.Error(), Err: pe}
+		}
+	}()
+	return fn.Call(args), nil
+}
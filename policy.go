@@ -0,0 +1,269 @@
+package goel
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Policy decides which types, packages, fields and methods an expression may use.  Denials take precedence over
+// allowances.  Once something of a kind is allowed, everything of that kind that is not allowed is denied: allowing
+// any type or package denies the types of all other packages, and allowing members of a type denies its other
+// members.  The predeclared types, such as int, string and error, are always allowed unless they are denied
+// explicitly.  A Policy must not be modified once it is used with the Sandbox option.
+type Policy struct {
+	allowedTypes    map[reflect.Type]bool
+	deniedTypes     map[reflect.Type]bool
+	allowedPackages map[string]bool
+	deniedPackages  map[string]bool
+	allowedMembers  map[reflect.Type]map[string]bool
+	deniedMembers   map[reflect.Type]map[string]bool
+	allowedFuncs    map[uintptr]bool
+	deniedFuncs     map[uintptr]bool
+}
+
+// NewPolicy creates a Policy that allows everything.
+func NewPolicy() *Policy {
+	return &Policy{
+		allowedTypes:    make(map[reflect.Type]bool),
+		deniedTypes:     make(map[reflect.Type]bool),
+		allowedPackages: make(map[string]bool),
+		deniedPackages:  make(map[string]bool),
+		allowedMembers:  make(map[reflect.Type]map[string]bool),
+		deniedMembers:   make(map[reflect.Type]map[string]bool),
+		allowedFuncs:    make(map[uintptr]bool),
+		deniedFuncs:     make(map[uintptr]bool),
+	}
+}
+
+// AllowTypes allows the types.
+func (p *Policy) AllowTypes(types ...reflect.Type) *Policy {
+	for _, typ := range types {
+		p.allowedTypes[typ] = true
+	}
+	return p
+}
+
+// DenyTypes denies the types.
+func (p *Policy) DenyTypes(types ...reflect.Type) *Policy {
+	for _, typ := range types {
+		p.deniedTypes[typ] = true
+	}
+	return p
+}
+
+// AllowPackages allows the types declared by the packages with the given import paths and by their subpackages.
+func (p *Policy) AllowPackages(paths ...string) *Policy {
+	for _, path := range paths {
+		p.allowedPackages[path] = true
+	}
+	return p
+}
+
+// DenyPackages denies the types declared by the packages with the given import paths and by their subpackages, e.g.
+// denying "net" denies "net/http" as well.
+func (p *Policy) DenyPackages(paths ...string) *Policy {
+	for _, path := range paths {
+		p.deniedPackages[path] = true
+	}
+	return p
+}
+
+// AllowMembers allows the fields and methods of typ, or of the type typ points to, with the given names.
+func (p *Policy) AllowMembers(typ reflect.Type, names ...string) *Policy {
+	addMembers(p.allowedMembers, indirectType(typ), names)
+	return p
+}
+
+// DenyMembers denies the fields and methods of typ, or of the type typ points to, with the given names.
+func (p *Policy) DenyMembers(typ reflect.Type, names ...string) *Policy {
+	addMembers(p.deniedMembers, indirectType(typ), names)
+	return p
+}
+
+// AllowFuncs allows the function values fns to be called.  Like PureRegistry.Funcs, functions are identified by their
+// code and AllowFuncs panics if one of fns is not a non-nil function or is a method value created by reflect.  Once a
+// function is allowed, calling any other function value is denied.  AllowPackages does not allow functions.
+func (p *Policy) AllowFuncs(fns ...interface{}) *Policy {
+	addFuncs(p.allowedFuncs, "AllowFuncs", fns)
+	return p
+}
+
+// DenyFuncs denies calling the function values fns, which are identified like with AllowFuncs.
+func (p *Policy) DenyFuncs(fns ...interface{}) *Policy {
+	addFuncs(p.deniedFuncs, "DenyFuncs", fns)
+	return p
+}
+
+func addFuncs(funcs map[uintptr]bool, method string, fns []interface{}) {
+	for _, fn := range fns {
+		v := reflect.ValueOf(fn)
+		if v.Kind() != reflect.Func || v.IsNil() {
+			panic(fmt.Sprintf("goel: %s expected a function but found %T", method, fn))
+		}
+		if v.Pointer() == reflectMethodValueCode {
+			panic(fmt.Sprintf("goel: %s can not identify a method value created by reflect", method))
+		}
+		funcs[v.Pointer()] = true
+	}
+}
+
+func addMembers(members map[reflect.Type]map[string]bool, typ reflect.Type, names []string) {
+	if members[typ] == nil {
+		members[typ] = make(map[string]bool)
+	}
+	for _, name := range names {
+		members[typ][name] = true
+	}
+}
+
+// Sandbox restricts the expression to the types, packages, fields and methods permitted by policy.  Using anything
+// else, including a value of a type that is not permitted, fails to compile with a PolicyViolation error.
+func Sandbox(policy *Policy) CompileOption {
+	return func(opts *compileOptions) {
+		opts.policy = policy
+	}
+}
+
+// permitsType reports whether the type typ and the types it is composed of are permitted.
+func (p *Policy) permitsType(typ reflect.Type) bool {
+	if typ == TupleType {
+		// The types of the elements were checked with the type of the function returning the tuple.
+		return true
+	}
+	if p.deniedTypes[typ] {
+		return false
+	}
+	if typ.Name() != "" {
+		return typ.PkgPath() == "" || !matchesPackage(p.deniedPackages, typ.PkgPath()) &&
+			(len(p.allowedTypes) == 0 && len(p.allowedPackages) == 0 || p.allowedTypes[typ] || matchesPackage(p.allowedPackages, typ.PkgPath()))
+	}
+	switch typ.Kind() {
+	case reflect.Array, reflect.Chan, reflect.Ptr, reflect.Slice:
+		return p.permitsType(typ.Elem())
+	case reflect.Map:
+		return p.permitsType(typ.Key()) && p.permitsType(typ.Elem())
+	case reflect.Func:
+		for i := 0; i < typ.NumIn(); i++ {
+			if !p.permitsType(typ.In(i)) {
+				return false
+			}
+		}
+		for i := 0; i < typ.NumOut(); i++ {
+			if !p.permitsType(typ.Out(i)) {
+				return false
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			if !p.permitsType(typ.Field(i).Type) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchesPackage reports whether the import path pkgPath is one of paths or a subpackage of one of them.
+func matchesPackage(paths map[string]bool, pkgPath string) bool {
+	for {
+		if paths[pkgPath] {
+			return true
+		}
+		i := strings.LastIndex(pkgPath, "/")
+		if i < 0 {
+			return false
+		}
+		pkgPath = pkgPath[:i]
+	}
+}
+
+// permitsMember reports whether the field or method name of typ is permitted.
+func (p *Policy) permitsMember(typ reflect.Type, name string) bool {
+	typ = indirectType(typ)
+	if p.deniedMembers[typ][name] {
+		return false
+	}
+	allowed, ok := p.allowedMembers[typ]
+	return !ok || allowed[name]
+}
+
+// permitsFunc reports whether the function value fn may be called.  A function is attributed to the package declaring
+// it, which must not be denied.  The method values created by reflect can not be attributed: if fn is a method bound
+// by BindStruct, recv is the type of its receiver and name the name of the method, which must both be permitted.
+func (p *Policy) permitsFunc(fn reflect.Value, recv reflect.Type, name string) bool {
+	if recv != nil {
+		return p.permitsType(recv) && p.permitsMember(recv, name) && p.permitsMember(declaringType(recv, name), name)
+	}
+	code := fn.Pointer()
+	if p.deniedFuncs[code] {
+		return false
+	}
+	if pkgPath := funcPackage(code); pkgPath != "" && matchesPackage(p.deniedPackages, pkgPath) {
+		return false
+	}
+	return len(p.allowedFuncs) == 0 || p.allowedFuncs[code]
+}
+
+// funcPackage returns the import path of the package declaring the function with the given code, or "" if it is not
+// known, from the symbol name of the function, e.g. "net/http.Get" or "example.com/pkg.(*T).M-fm".
+func funcPackage(code uintptr) string {
+	if code == reflectMethodValueCode {
+		return ""
+	}
+	f := runtime.FuncForPC(code)
+	if f == nil {
+		return ""
+	}
+	name := f.Name()
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return ""
+	}
+	// The dots in the last element of the import path are escaped in symbol names.
+	return strings.ReplaceAll(name[:slash+1+dot], "%2e", ".")
+}
+
+// checkFuncPolicy returns an error unless the policy of the call permits calling fn.  If fn is a method bound by
+// BindStruct, recv is the type of its receiver.
+func (cce *callCompiledExpression) checkFuncPolicy(recv reflect.Type, fn reflect.Value) error {
+	if cce.policy == nil || cce.policy.permitsFunc(fn, recv, cce.name) {
+		return nil
+	}
+	return runtimeErrorf(cce.exp.Lparen, PolicyViolation, "call of %s is not permitted", cce.name)
+}
+
+// checkPolicy returns cexp unless the policy of the compile options does not permit its type.
+func checkPolicy(options *compileOptions, exp ast.Expr, cexp compiledExpression) compiledExpression {
+	if options.policy == nil || cexp.Error() != nil {
+		return cexp
+	}
+	typ, _ := cexp.ReturnType()
+	if typ != nil && !options.policy.permitsType(typ) {
+		return newErrorExpression(compileErrorf(exp.Pos(), PolicyViolation, "use of type %s is not permitted", typ))
+	}
+	return cexp
+}
+
+// checkMemberPolicy returns an error unless the policy of the compile options permits the field or method name of
+// typ.  A member promoted from an embedded field, which is reached through the index sequence path of embedded
+// fields, must also be permitted for the type declaring it.
+func checkMemberPolicy(options *compileOptions, pos token.Pos, typ reflect.Type, path []int, name string) error {
+	if options.policy == nil {
+		return nil
+	}
+	declaring := indirectType(typ)
+	for _, i := range path {
+		declaring = indirectType(declaring.Field(i).Type)
+	}
+	for _, t := range []reflect.Type{typ, declaring} {
+		if !options.policy.permitsMember(t, name) {
+			return compileErrorf(pos, PolicyViolation, "use of %s.%s is not permitted", indirectType(t), name)
+		}
+	}
+	return nil
+}
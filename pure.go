@@ -0,0 +1,180 @@
+package goel
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+)
+
+// PureRegistry registers the functions and methods that an expression compiled with the Pure option may call.
+// A PureRegistry must not be modified once it is used with the Pure option.
+type PureRegistry struct {
+	funcs   map[uintptr]bool
+	types   map[reflect.Type]bool
+	methods map[reflect.Type]map[string]bool
+}
+
+// NewPureRegistry creates an empty PureRegistry.
+func NewPureRegistry() *PureRegistry {
+	return &PureRegistry{make(map[uintptr]bool), make(map[reflect.Type]bool), make(map[reflect.Type]map[string]bool)}
+}
+
+// Funcs registers the functions fns as pure.  Functions are identified by their code so that every closure created
+// by the same function literal is registered at once.  reflect creates all method values with the same code so they
+// can not be registered as functions: register the methods of their receivers with Types or Methods instead.  Funcs
+// panics if one of fns is not a non-nil function or is a method value created by reflect.
+func (pr *PureRegistry) Funcs(fns ...interface{}) *PureRegistry {
+	for _, fn := range fns {
+		v := reflect.ValueOf(fn)
+		if v.Kind() != reflect.Func || v.IsNil() {
+			panic(fmt.Sprintf("goel: Funcs expected a function but found %T", fn))
+		}
+		if v.Pointer() == reflectMethodValueCode {
+			panic("goel: Funcs can not register a method value created by reflect, use Types or Methods")
+		}
+		pr.funcs[v.Pointer()] = true
+	}
+	return pr
+}
+
+// reflectMethodValueCode is the code shared by the method values created by reflect.
+var reflectMethodValueCode = reflect.ValueOf(UnknownErrorKind).MethodByName("String").Pointer()
+
+// Types registers all of the value receiver methods of the types as pure.  Methods promoted from embedded fields must
+// also have value receivers in the type declaring them.
+func (pr *PureRegistry) Types(types ...reflect.Type) *PureRegistry {
+	for _, typ := range types {
+		pr.types[indirectType(typ)] = true
+	}
+	return pr
+}
+
+// Methods registers the methods of typ, or of the type typ points to, with the given names as pure.  Methods with
+// pointer receivers are rejected even if they are registered.
+func (pr *PureRegistry) Methods(typ reflect.Type, names ...string) *PureRegistry {
+	addMembers(pr.methods, indirectType(typ), names)
+	return pr
+}
+
+// Pure compiles the expression in pure mode, which guarantees that executing it does not modify its inputs.  In pure
+// mode, an expression may only call the functions and methods registered as pure in registry, methods with pointer
+// receivers are rejected and its result can not be a value, such as a pointer, a map, a slice or a function, through
+// which its inputs could be modified.  Calls of methods and results are checked when compiling, or when executing if
+// the result is an interface.  Calls of function values are checked when executing.  Violations are reported as
+// errors of kind Impure.
+func Pure(registry *PureRegistry) CompileOption {
+	return func(opts *compileOptions) {
+		opts.pure = registry
+	}
+}
+
+// checkPureMethod returns an error unless the method name of typ may be called in pure mode.
+func (pr *PureRegistry) checkPureMethod(pos token.Pos, typ reflect.Type, name string) error {
+	if msg := pr.pureMethodViolation(typ, name); msg != "" {
+		return compileErrorf(pos, Impure, "%s", msg)
+	}
+	return nil
+}
+
+// pureMethodViolation describes why the method name of typ may not be called in pure mode or returns "" if it may.
+// A method promoted from an embedded field must have a value receiver in the type declaring it, even if it is
+// promoted through a pointer, and must be registered for typ or for that type.
+func (pr *PureRegistry) pureMethodViolation(typ reflect.Type, name string) string {
+	t, declaring := indirectType(typ), declaringType(typ, name)
+	if declaring.Kind() != reflect.Interface {
+		if _, ok := declaring.MethodByName(name); !ok {
+			return fmt.Sprintf("method %s.%s has a pointer receiver", declaring, name)
+		}
+	}
+	if !pr.types[t] && !pr.methods[t][name] && !pr.types[declaring] && !pr.methods[declaring][name] {
+		return fmt.Sprintf("method %s.%s is not registered as pure", t, name)
+	}
+	return ""
+}
+
+// checkPureFunc returns an error unless fn may be called in pure mode.  If fn is a method bound by BindStruct, recv is
+// the type of its receiver and name the name of the method.
+func (pr *PureRegistry) checkPureFunc(pos token.Pos, name string, fn reflect.Value, recv reflect.Type) error {
+	if recv != nil {
+		if msg := pr.pureMethodViolation(recv, name); msg != "" {
+			return runtimeErrorf(pos, Impure, "%s", msg)
+		}
+		return nil
+	}
+	if fn.Pointer() != reflectMethodValueCode && pr.funcs[fn.Pointer()] {
+		return nil
+	}
+	return runtimeErrorf(pos, Impure, "function %s is not registered as pure", name)
+}
+
+// isMutableType reports whether the inputs of an expression could be modified through a value of type typ.
+// Interfaces and tuples are not considered mutable as their dynamic values are checked by isMutableValue.
+func isMutableType(typ reflect.Type) bool {
+	if typ == TupleType {
+		return false
+	}
+	switch typ.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Map, reflect.Ptr, reflect.Slice, reflect.UnsafePointer:
+		return true
+	case reflect.Array:
+		return isMutableType(typ.Elem())
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			if isMutableType(typ.Field(i).Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isMutableValue reports whether the inputs of an expression could be modified through v.
+func isMutableValue(v reflect.Value) bool {
+	if v.IsValid() && v.Type() == TupleType {
+		for _, e := range v.Interface().(Tuple) {
+			if e != nil && isMutableValue(reflect.ValueOf(e)) {
+				return true
+			}
+		}
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Map, reflect.Ptr, reflect.Slice, reflect.UnsafePointer:
+		return !v.IsNil()
+	case reflect.Interface:
+		return isMutableValue(v.Elem())
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if isMutableValue(v.Index(i)) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if isMutableValue(v.Field(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pureResult returns an error if the result of a pure expression compiled at pos could be used to modify its inputs.
+func pureResult(pos token.Pos, result interface{}) error {
+	if result != nil && isMutableValue(reflect.ValueOf(result)) {
+		return runtimeErrorf(pos, Impure, "result of type %T could modify the inputs", result)
+	}
+	return nil
+}
+
+// checkPureExpression returns an error if the result of cexp could be used to modify the inputs.
+func checkPureExpression(options *compileOptions, cexp compiledExpression) error {
+	if options.pure == nil || cexp.Error() != nil {
+		return nil
+	}
+	typ, _ := cexp.ReturnType()
+	if typ != nil && isMutableType(typ) {
+		return compileErrorf(cexp.Pos(), Impure, "result of type %s could modify the inputs", typ)
+	}
+	return nil
+}
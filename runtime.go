@@ -0,0 +1,154 @@
+package goel
+
+import (
+	"go/token"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// arithmeticError is raised by the checked arithmetic operators.  It implements runtime.Error so it is reported the
+// same way as the faults raised by go itself, e.g. integer division by zero.
+type arithmeticError string
+
+func (ae arithmeticError) Error() string {
+	return "runtime error: " + string(ae)
+}
+
+func (ae arithmeticError) RuntimeError() {}
+
+const errIntegerOverflow = arithmeticError("integer overflow")
+
+const minInt = -1 << (strconv.IntSize - 1)
+
+// recoverRuntimePanic must be deferred by Execute methods that can fault.  It converts a panic into an error that is
+// positioned at pos and stores it in err.  A panic propagated from a function with the PanicPropagate policy keeps
+// unwinding.
+func recoverRuntimePanic(pos token.Pos, err *error) {
+	if r := recover(); r != nil {
+		if pp, ok := r.(*propagatedPanic); ok {
+			panic(pp)
+		}
+		*err = runtimePanicError(pos, r)
+	}
+}
+
+func addintchecked(l, r interface{}) interface{} {
+	x, y := l.(int), r.(int)
+	s := x + y
+	if (s > x) != (y > 0) {
+		panic(errIntegerOverflow)
+	}
+	return s
+}
+
+func subintchecked(l, r interface{}) interface{} {
+	x, y := l.(int), r.(int)
+	d := x - y
+	if (d < x) != (y > 0) {
+		panic(errIntegerOverflow)
+	}
+	return d
+}
+
+func mulintchecked(l, r interface{}) interface{} {
+	x, y := l.(int), r.(int)
+	if x == 0 || y == 0 {
+		return 0
+	}
+	p := x * y
+	if p/y != x || (x == -1 && y == minInt) || (y == -1 && x == minInt) {
+		panic(errIntegerOverflow)
+	}
+	return p
+}
+
+func divintchecked(l, r interface{}) interface{} {
+	x, y := l.(int), r.(int)
+	if y == -1 && x == minInt {
+		panic(errIntegerOverflow)
+	}
+	return x / y
+}
+
+func addint64checked(l, r interface{}) interface{} {
+	x, y := l.(int64), r.(int64)
+	s := x + y
+	if (s > x) != (y > 0) {
+		panic(errIntegerOverflow)
+	}
+	return s
+}
+
+func subint64checked(l, r interface{}) interface{} {
+	x, y := l.(int64), r.(int64)
+	d := x - y
+	if (d < x) != (y > 0) {
+		panic(errIntegerOverflow)
+	}
+	return d
+}
+
+func mulint64checked(l, r interface{}) interface{} {
+	x, y := l.(int64), r.(int64)
+	if x == 0 || y == 0 {
+		return int64(0)
+	}
+	p := x * y
+	if p/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+		panic(errIntegerOverflow)
+	}
+	return p
+}
+
+func divint64checked(l, r interface{}) interface{} {
+	x, y := l.(int64), r.(int64)
+	if y == -1 && x == math.MinInt64 {
+		panic(errIntegerOverflow)
+	}
+	return x / y
+}
+
+func adduint64checked(l, r interface{}) interface{} {
+	x, y := l.(uint64), r.(uint64)
+	s := x + y
+	if s < x {
+		panic(errIntegerOverflow)
+	}
+	return s
+}
+
+func subuint64checked(l, r interface{}) interface{} {
+	x, y := l.(uint64), r.(uint64)
+	if y > x {
+		panic(errIntegerOverflow)
+	}
+	return x - y
+}
+
+func muluint64checked(l, r interface{}) interface{} {
+	x, y := l.(uint64), r.(uint64)
+	if x == 0 || y == 0 {
+		return uint64(0)
+	}
+	p := x * y
+	if p/y != x {
+		panic(errIntegerOverflow)
+	}
+	return p
+}
+
+func negateintchecked(v interface{}) interface{} {
+	if v.(int) == minInt {
+		panic(errIntegerOverflow)
+	}
+	return -v.(int)
+}
+
+// checkedOperators replaces the integer operators that can overflow when checked arithmetic is enabled.
+var checkedOperators = map[token.Token]map[reflect.Type]func(l, r interface{}) interface{}{
+	token.ADD: {IntType: addintchecked, Int64Type: addint64checked, Uint64Type: adduint64checked},
+	token.SUB: {IntType: subintchecked, Int64Type: subint64checked, Uint64Type: subuint64checked},
+	token.MUL: {IntType: mulintchecked, Int64Type: mulint64checked, Uint64Type: muluint64checked},
+	token.QUO: {IntType: divintchecked, Int64Type: divint64checked},
+}
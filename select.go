@@ -2,10 +2,10 @@ package goel
 
 import (
 	"context"
-	"github.com/pkg/errors"
 	"go/ast"
 	"go/token"
 	"reflect"
+	"unsafe"
 )
 
 type selectCompiledExpression struct {
@@ -16,36 +16,66 @@ type selectCompiledExpression struct {
 	selType  reflect.Type
 	isMethod bool
 	pos      token.Pos
+	// index is the index sequence of a field or, for a method promoted from an embedded field, of that field.
+	index []int
+	// unexported is set for an unexported field read with the AllowUnexported option.
+	unexported bool
+	// takeAddr is set when the expression evaluates to the address of the field rather than its value.
+	takeAddr bool
 }
 
-func (sce *selectCompiledExpression) HasOwner() bool {
-	return sce.xtyp.Kind() != reflect.Interface
+// addressable is implemented by the expressions that may denote addressable values, such as the fields of structs
+// reached through pointers and the elements of slices, so that methods with pointer receivers can be called on them.
+type addressable interface {
+	// addr returns an expression evaluating to the address of the value or nil if the value is not addressable.
+	addr() compiledExpression
 }
 
-func (sce *selectCompiledExpression) Execute(ectx context.Context) (interface{}, error) {
+func (sce *selectCompiledExpression) Execute(ectx context.Context) (result interface{}, err error) {
+	defer recoverRuntimePanic(sce.pos, &err)
+	if err := checkpoint(ectx, sce.pos); err != nil {
+		return nil, err
+	}
 	x, err := sce.x.Execute(ectx)
 	if err != nil {
 		return nil, err
 	}
 	if x == nil {
-		return nil, errors.Errorf("%d: dereferencing a nil value", sce.pos)
+		return nil, runtimeErrorf(sce.pos, NilDereference, "dereferencing a nil value")
 	}
 	xValue := reflect.ValueOf(x)
 	if !xValue.IsValid() {
-		return nil, errors.Errorf("%d: value is invalid!", sce.pos)
+		return nil, runtimeErrorf(sce.pos, NilDereference, "value is invalid!")
 	}
-	var fValue reflect.Value
-	if sce.isMethod {
-		fValue = xValue.MethodByName(sce.name)
-	} else if xValue.Kind() == reflect.Ptr && xValue.Elem().Kind() == reflect.Struct {
-		fValue = xValue.Elem().FieldByName(sce.name)
-	} else if xValue.Kind() == reflect.Struct {
-		fValue = xValue.FieldByName(sce.name)
+	isNilPtr := xValue.Kind() == reflect.Ptr && xValue.IsNil()
+	if isNilPtr && (!sce.isMethod || len(sce.index) > 0) {
+		return nil, runtimeErrorf(sce.pos, NilDereference, "nil pointer dereference")
 	}
-	if fValue.IsValid() {
-		return fValue.Interface(), nil
+	if !sce.isMethod || len(sce.index) > 0 {
+		if xv := reflect.Indirect(xValue); xv.Kind() == reflect.Struct {
+			var fValue reflect.Value
+			if sce.unexported {
+				fValue, err = readUnexportedField(xv, sce.index)
+			} else {
+				fValue, err = fieldByIndex(xv, sce.index)
+			}
+			if err != nil {
+				return nil, runtimeErrorf(sce.pos, NilDereference, "%v", err)
+			}
+			if !sce.isMethod {
+				if sce.takeAddr {
+					return fValue.Addr().Interface(), nil
+				}
+				return fValue.Interface(), nil
+			}
+		}
+	}
+	if sce.isMethod {
+		if fValue := xValue.MethodByName(sce.name); fValue.IsValid() {
+			return fValue.Interface(), nil
+		}
 	}
-	return nil, errors.Errorf("%d: unknown selector %s for %T", sce.pos, sce.name, x)
+	return nil, runtimeErrorf(sce.pos, UnknownSelector, "unknown selector %s for %T", sce.name, x)
 }
 
 func (sce *selectCompiledExpression) Error() error {
@@ -56,35 +86,193 @@ func (sce *selectCompiledExpression) ReturnType() (reflect.Type, error) {
 	return sce.selType, nil
 }
 
+// addr returns an expression evaluating to the address of the field if it is reached through a pointer or through an
+// addressable struct.
+func (sce *selectCompiledExpression) addr() compiledExpression {
+	if sce.isMethod || sce.unexported {
+		return nil
+	}
+	c := *sce
+	if c.xtyp.Kind() != reflect.Ptr {
+		ax, ok := c.x.(addressable)
+		if !ok {
+			return nil
+		}
+		x := ax.addr()
+		if x == nil {
+			return nil
+		}
+		c.x, c.xtyp = x, reflect.PtrTo(c.xtyp)
+	}
+	c.selType, c.takeAddr = reflect.PtrTo(c.selType), true
+	return &c
+}
+
+// selection is a field or method found by resolveSelector.
+type selection struct {
+	// index is the index sequence of the field or, for a method, of the embedded field declaring it.
+	index    []int
+	field    reflect.StructField
+	isMethod bool
+}
+
+// embedding is a type reached through the sequence of embedded fields index while resolving a selector.
+type embedding struct {
+	typ   reflect.Type
+	index []int
+}
+
+// resolveSelector resolves the selector name of a value of type typ as go does: it returns the field or method found
+// at the shallowest depth of embedding and whether it was found.  If there are several at that depth, the selector
+// is ambiguous and the number found is returned.
+func resolveSelector(typ reflect.Type, name string) (selection, int) {
+	return resolveEmbedded([]embedding{{indirectType(typ), nil}}, name, make(map[reflect.Type]bool))
+}
+
+// resolveEmbedded resolves the selector name like resolveSelector, starting from the types in current and descending
+// breadth first into the types they embed.  The types in visiting are being resolved by an enclosing call and are not
+// descended into again.
+func resolveEmbedded(current []embedding, name string, visiting map[reflect.Type]bool) (selection, int) {
+	seen := make(map[reflect.Type]bool)
+	for len(current) > 0 {
+		var found []selection
+		var next []embedding
+		for _, e := range current {
+			if seen[e.typ] || visiting[e.typ] {
+				continue
+			}
+			if declaresMethod(e.typ, name, visiting) {
+				found = append(found, selection{index: e.index, isMethod: true})
+			}
+			if e.typ.Kind() != reflect.Struct {
+				continue
+			}
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+				index := append(append([]int{}, e.index...), i)
+				if sf.Name == name {
+					found = append(found, selection{index: index, field: sf})
+				}
+				if sf.Anonymous {
+					next = append(next, embedding{indirectType(sf.Type), index})
+				}
+			}
+		}
+		if len(found) > 0 {
+			return found[0], len(found)
+		}
+		for _, e := range current {
+			seen[e.typ] = true
+		}
+		current = next
+	}
+	return selection{}, 0
+}
+
+// declaresMethod reports whether typ has a method name that is not promoted from one of its embedded fields.  The
+// method is promoted if exactly one method name, and no field name, is found at the shallowest depth of the fields
+// typ embeds.  reflect can not tell whether typ also declares a method shadowing a method promoted this way, so such a
+// method is attributed to the embedded field, although calling it still calls the method typ declares.
+func declaresMethod(typ reflect.Type, name string, visiting map[reflect.Type]bool) bool {
+	if typ.Kind() == reflect.Interface {
+		_, ok := typ.MethodByName(name)
+		return ok
+	}
+	if _, ok := reflect.PtrTo(typ).MethodByName(name); !ok {
+		return false
+	}
+	if typ.Kind() != reflect.Struct {
+		return true
+	}
+	var embedded []embedding
+	for i := 0; i < typ.NumField(); i++ {
+		if sf := typ.Field(i); sf.Anonymous {
+			embedded = append(embedded, embedding{indirectType(sf.Type), sf.Index})
+		}
+	}
+	visiting[typ] = true
+	defer delete(visiting, typ)
+	sel, found := resolveEmbedded(embedded, name, visiting)
+	return found != 1 || !sel.isMethod
+}
+
+// declaringType returns the type declaring the method name of typ, which is the type of the embedded field the method
+// is promoted from, if any, or typ itself.  Pointers are dereferenced.
+func declaringType(typ reflect.Type, name string) reflect.Type {
+	declaring := indirectType(typ)
+	if sel, found := resolveSelector(declaring, name); found == 1 && sel.isMethod {
+		for _, i := range sel.index {
+			declaring = indirectType(declaring.Field(i).Type)
+		}
+	}
+	return declaring
+}
+
 func evalSelectorExpr(pctx context.Context, exp *ast.SelectorExpr) compiledExpression {
 	xexp := compile(pctx, exp.X)
 	if xexp.Error() != nil {
 		return xexp
 	}
 	xtyp, _ := xexp.ReturnType()
-	var selTyp reflect.Type
-	var isMethod bool = false
-	if xtyp.Kind() == reflect.Struct {
-		sf, ok := xtyp.FieldByName(exp.Sel.Name)
-		if ok {
-			selTyp = sf.Type
-		}
+	options := compileOptionsFrom(pctx)
+	name := exp.Sel.Name
+	sel, found := resolveSelector(xtyp, name)
+	if xtyp.Kind() == reflect.Ptr && xtyp.Elem().Kind() == reflect.Ptr {
+		found = 0
 	}
-	if xtyp.Kind() == reflect.Ptr && xtyp.Elem().Kind() == reflect.Struct {
-		sf, ok := xtyp.Elem().FieldByName(exp.Sel.Name)
-		if ok {
-			selTyp = sf.Type
-		}
+	unexported := !ast.IsExported(name)
+	switch {
+	case found > 1:
+		return newErrorExpression(compileErrorf(exp.Sel.NamePos, UnknownSelector, "ambiguous selector %s for %s", name, xtyp))
+	case found == 1 && !sel.isMethod && unexported && !options.allowUnexported:
+		return newErrorExpression(compileErrorf(exp.Sel.NamePos, Unexported, "cannot refer to unexported field %s of %s", name, xtyp))
+	case (found == 0 || sel.isMethod) && unexported:
+		return newErrorExpression(compileErrorf(exp.Sel.NamePos, Unexported, "cannot refer to unexported method or field %s of %s", name, xtyp))
+	case found == 0:
+		return newErrorExpression(compileErrorf(exp.Sel.NamePos, UnknownSelector, "unknown selector %s for %s", name, xtyp.String()))
+	}
+	path := sel.index
+	if !sel.isMethod {
+		path = path[:len(path)-1]
 	}
-	if selTyp == nil {
-		ok := false
-		var mf reflect.Method
-		mf, ok = xtyp.MethodByName(exp.Sel.Name)
+	if err := checkMemberPolicy(options, exp.Sel.NamePos, xtyp, path, name); err != nil {
+		return newErrorExpression(err)
+	}
+	if !sel.isMethod {
+		return &selectCompiledExpression{nopExpression{exp}, xexp, xtyp, name, sel.field.Type, false, exp.Pos(), sel.index, unexported, false}
+	}
+	mf, ok := xtyp.MethodByName(name)
+	if !ok {
+		// The method has a pointer receiver, which go allows to be called on addressable values.
+		if ax, isAddressable := xexp.(addressable); isAddressable {
+			if x := ax.addr(); x != nil {
+				xexp, xtyp = x, reflect.PtrTo(xtyp)
+				mf, ok = xtyp.MethodByName(name)
+			}
+		}
 		if !ok {
-			return newErrorExpression(errors.Errorf("%d: unknown selector %s for %s", exp.Sel.NamePos, exp.Sel.Name, xtyp.String()))
+			return newErrorExpression(compileErrorf(exp.Sel.NamePos, UnknownSelector, "cannot call pointer method %s on %s", name, xtyp))
 		}
-		selTyp = mf.Type
-		isMethod = true
 	}
-	return &selectCompiledExpression{nopExpression{exp}, xexp, xtyp, exp.Sel.Name, selTyp, isMethod, exp.Pos()}
+	selTyp := mf.Type
+	if xtyp.Kind() != reflect.Interface {
+		selTyp = methodValueType(mf.Type)
+	}
+	return &selectCompiledExpression{nopExpression{exp}, xexp, xtyp, name, selTyp, true, exp.Pos(), sel.index, false, false}
+}
+
+// readUnexportedField returns the value of the unexported field with the index sequence index of the struct
+// sv.  reflect does not allow unexported fields to be read through Interface so the field is read through its address
+// in sv, or in a copy of sv if it is not addressable, which the returned value is not restricted by.
+func readUnexportedField(sv reflect.Value, index []int) (reflect.Value, error) {
+	if !sv.CanAddr() {
+		c := reflect.New(sv.Type()).Elem()
+		c.Set(sv)
+		sv = c
+	}
+	f, err := fieldByIndex(sv, index)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem(), nil
 }
@@ -2,8 +2,8 @@ package goel
 
 import (
 	"context"
-	"github.com/pkg/errors"
 	"go/ast"
+	"go/token"
 	"reflect"
 )
 
@@ -26,22 +26,26 @@ func verifyIntExpression(executionContext context.Context, lexp compiledExpressi
 	}
 	l, ok := _l.(int)
 	if !ok {
-		return -1, errors.Errorf("%d: type mismatch expected an int but found %T", lexp.Pos(), _l)
+		return -1, runtimeErrorf(lexp.Pos(), TypeMismatch, "type mismatch expected an int but found %T", _l)
 	}
 	if min <= l && l <= max {
 		return l, nil
 	}
-	return -1, errors.Errorf("%d: index out of range: %d", lexp.Pos(), l)
+	return -1, runtimeErrorf(lexp.Pos(), IndexOutOfRange, "index out of range: %d", l)
 }
 
-func (sce *sliceCompiledExpression) Execute(executionContext context.Context) (interface{}, error) {
+func (sce *sliceCompiledExpression) Execute(executionContext context.Context) (result interface{}, err error) {
+	defer recoverRuntimePanic(sce.sliceExp.Lbrack, &err)
+	if err := checkpoint(executionContext, sce.sliceExp.Lbrack); err != nil {
+		return nil, err
+	}
 	x, err := sce.xexp.Execute(executionContext)
 	if err != nil {
 		return nil, err
 	}
 	xv := reflect.ValueOf(x)
 	if xv.Kind() != reflect.Slice && xv.Kind() != reflect.String {
-		return nil, errors.Errorf("%d: type mismatch expected a slice or string but found %T", sce.xexp.Pos(), x)
+		return nil, runtimeErrorf(sce.xexp.Pos(), TypeMismatch, "type mismatch expected a slice or string but found %T", x)
 	}
 	l, err := verifyIntExpression(executionContext, sce.lexp, 0, xv.Len()-1)
 	if err != nil {
@@ -53,24 +57,39 @@ func (sce *sliceCompiledExpression) Execute(executionContext context.Context) (i
 	}
 	if sce.slice3 {
 		if xv.Kind() != reflect.Slice {
-			return nil, errors.Errorf("%d: type mismatch expected a slice but found %T", sce.xexp.Pos(), x)
+			return nil, runtimeErrorf(sce.xexp.Pos(), TypeMismatch, "type mismatch expected a slice but found %T", x)
 		}
 		m, err := verifyIntExpression(executionContext, sce.mexp, h, xv.Cap())
 		if err != nil {
 			return nil, err
 		}
+		if err := chargeSlice(executionContext, sce.sliceExp.Lbrack, xv, h-l); err != nil {
+			return nil, err
+		}
 		return xv.Slice3(l, h, m).Interface(), nil
 	}
+	if err := chargeSlice(executionContext, sce.sliceExp.Lbrack, xv, h-l); err != nil {
+		return nil, err
+	}
 	return xv.Slice(l, h).Interface(), nil
 }
 
+// chargeSlice counts the bytes of n elements of the slice or string xv against the budget of the execution before
+// xv is sliced.
+func chargeSlice(ectx context.Context, pos token.Pos, xv reflect.Value, n int) error {
+	if xv.Kind() == reflect.Slice {
+		n *= int(xv.Type().Elem().Size())
+	}
+	return chargeBytes(ectx, pos, n)
+}
+
 func newSliceCompiledExpression(sliceExp *ast.SliceExpr, returnType reflect.Type, xexp, hexp, lexp, mexp compiledExpression, slice3 bool) compiledExpression {
 	return &sliceCompiledExpression{nopExpression{sliceExp}, sliceExp, xexp, hexp, lexp, mexp, returnType, slice3}
 }
 
 type lengthCompiledExpression struct {
 	nopExpression
-	xexp CompiledExpression
+	xexp compiledExpression
 }
 
 func (lce *lengthCompiledExpression) ReturnType() (reflect.Type, error) {
@@ -86,7 +105,7 @@ func (lce *lengthCompiledExpression) Execute(executionContext context.Context) (
 	if vs.Kind() == reflect.Array || vs.Kind() == reflect.Slice || vs.Kind() == reflect.String {
 		return vs.Len(), nil
 	}
-	return nil, errors.Errorf("%d: expected an array, slice, or string found %T", 0, s)
+	return nil, runtimeErrorf(lce.xexp.Pos(), TypeMismatch, "expected an array, slice, or string found %T", s)
 }
 
 func newLengthCompiledExpression(xexp compiledExpression) compiledExpression {
@@ -95,17 +114,6 @@ func newLengthCompiledExpression(xexp compiledExpression) compiledExpression {
 
 func evalSliceExpr(pctx context.Context, exp *ast.SliceExpr) compiledExpression {
 	xexp := compile(pctx, exp.X)
-	if xexp.Error() != nil {
-		return xexp
-	}
-	xt, _ := xexp.ReturnType()
-	if (xt.Kind() != reflect.Slice && xt.Kind() != reflect.String) || (exp.Slice3 && xt.Kind() == reflect.String) {
-		if exp.Slice3 {
-			return newErrorExpression(errors.Errorf("%d: type mismatch expected a slice but found %s", xexp.Pos(), xt))
-		}
-		return newErrorExpression(errors.Errorf("%d: type mismatch expected a slice or string but found %s", xexp.Pos(), xt))
-	}
-	returnType := xt
 	var hexp, lexp, mexp compiledExpression
 	if exp.Low != nil {
 		lexp = compile(pctx, exp.Low)
@@ -114,14 +122,23 @@ func evalSliceExpr(pctx context.Context, exp *ast.SliceExpr) compiledExpression
 	}
 	if exp.High != nil {
 		hexp = compile(pctx, exp.High)
-	} else {
-		hexp = newLengthCompiledExpression(xexp)
 	}
 	if exp.Slice3 {
-		if xt.Kind() == reflect.String {
-			return newErrorExpression(errors.Errorf("%d: type mismatch expected an array or slice but found %s", xexp.Pos(), xt.Name()))
-		}
 		mexp = compile(pctx, exp.Max)
 	}
+	if failed := collectErrors(pctx, xexp, lexp, hexp, mexp); failed != nil {
+		return failed
+	}
+	xt, _ := xexp.ReturnType()
+	if (xt.Kind() != reflect.Slice && xt.Kind() != reflect.String) || (exp.Slice3 && xt.Kind() == reflect.String) {
+		if exp.Slice3 {
+			return newErrorExpression(compileErrorf(xexp.Pos(), TypeMismatch, "type mismatch expected a slice but found %s", xt))
+		}
+		return newErrorExpression(compileErrorf(xexp.Pos(), TypeMismatch, "type mismatch expected a slice or string but found %s", xt))
+	}
+	returnType := xt
+	if hexp == nil {
+		hexp = newLengthCompiledExpression(xexp)
+	}
 	return newSliceCompiledExpression(exp, returnType, xexp, hexp, lexp, mexp, exp.Slice3)
 }
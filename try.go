@@ -0,0 +1,58 @@
+package goel
+
+import (
+	"context"
+	"go/ast"
+	"reflect"
+)
+
+type tryCompiledExpression struct {
+	nopExpression
+	exp      *ast.CallExpr
+	xexp     compiledExpression
+	fallback compiledExpression
+	typ      reflect.Type
+}
+
+func (tce *tryCompiledExpression) ReturnType() (reflect.Type, error) {
+	return tce.typ, nil
+}
+
+func (tce *tryCompiledExpression) Execute(ectx context.Context) (interface{}, error) {
+	if err := checkpoint(ectx, tce.exp.Lparen); err != nil {
+		return nil, err
+	}
+	v, err := tce.xexp.Execute(ectx)
+	if err == nil {
+		return v, nil
+	}
+	if re, ok := err.(*RuntimeError); ok && (re.Kind == Canceled || re.Kind == BudgetExceeded) {
+		return nil, err
+	}
+	return tce.fallback.Execute(ectx)
+}
+
+// isTryBuiltin reports whether exp is a call of the try builtin, which a declared identifier named try shadows.
+func isTryBuiltin(pctx context.Context, exp *ast.CallExpr) bool {
+	ident, ok := exp.Fun.(*ast.Ident)
+	return ok && ident.Name == "try" && lookupDeclaration(pctx, ident.Name) == nil
+}
+
+// evalTryExpr compiles try(x, fallback), which evaluates to x or, if evaluating x fails, to fallback.  Only the
+// cancellation of the execution and an exceeded budget are not recovered from.
+func evalTryExpr(pctx context.Context, exp *ast.CallExpr) compiledExpression {
+	if len(exp.Args) != 2 {
+		return newErrorExpression(compileErrorf(exp.Rparen, Arity, "wrong number of arguments to try, expected 2, found %d", len(exp.Args)))
+	}
+	xexp := compile(pctx, exp.Args[0])
+	fallback := compile(pctx, exp.Args[1])
+	if failed := collectErrors(pctx, xexp, fallback); failed != nil {
+		return failed
+	}
+	typ, _ := xexp.ReturnType()
+	ftyp, _ := fallback.ReturnType()
+	if ftyp == nil && !isNillable(typ) || ftyp != nil && !ftyp.AssignableTo(typ) {
+		return newErrorExpression(compileErrorf(exp.Args[1].Pos(), TypeMismatch, "type mismatch in try, expected %s but found %v", typ, ftyp))
+	}
+	return &tryCompiledExpression{nopExpression{exp}, exp, xexp, fallback, typ}
+}
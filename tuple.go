@@ -0,0 +1,72 @@
+package goel
+
+import (
+	"context"
+	"go/ast"
+	"reflect"
+)
+
+// Tuple is the value of a call of a function with several results, excluding a trailing error.  An element of a
+// Tuple can be selected with a constant index, as in f()[1], which has the static type of that result, and a Tuple
+// can be passed as the arguments of a function with matching parameters, as in f(g()).
+type Tuple []interface{}
+
+// TupleType is a reflect.Type for Tuple
+var TupleType = reflect.TypeOf(Tuple(nil))
+
+type tupleIndexCompiledExpression struct {
+	nopExpression
+	exp   *ast.IndexExpr
+	xexp  compiledExpression
+	index int
+	typ   reflect.Type
+}
+
+func (tice *tupleIndexCompiledExpression) ReturnType() (reflect.Type, error) {
+	return tice.typ, nil
+}
+
+func (tice *tupleIndexCompiledExpression) Execute(ectx context.Context) (interface{}, error) {
+	if err := checkpoint(ectx, tice.exp.Lbrack); err != nil {
+		return nil, err
+	}
+	x, err := tice.xexp.Execute(ectx)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := x.(Tuple)
+	if !ok || tice.index >= len(t) {
+		return nil, runtimeErrorf(tice.exp.X.Pos(), TypeMismatch, "type mismatch expected a tuple but found %T", x)
+	}
+	return t[tice.index], nil
+}
+
+func (tice *tupleIndexCompiledExpression) cost() Cost {
+	return step.add(costOf(tice.xexp))
+}
+
+// tupleResultTypes returns the types of the elements of the Tuple cexp evaluates to or nil if it does not evaluate to
+// a Tuple.
+func tupleResultTypes(cexp compiledExpression) []reflect.Type {
+	if cce, ok := cexp.(*callCompiledExpression); ok {
+		return cce.resultTypes
+	}
+	return nil
+}
+
+// evalTupleIndexExpr compiles the selection of the element of the Tuple xexp evaluates to, whose types are
+// resultTypes, by the constant index iexp.
+func evalTupleIndexExpr(exp *ast.IndexExpr, xexp, iexp compiledExpression, resultTypes []reflect.Type) compiledExpression {
+	lit, ok := iexp.(*literalCompiledExpression)
+	if !ok {
+		return newErrorExpression(compileErrorf(exp.Index.Pos(), UnsupportedExpression, "tuple index must be a constant"))
+	}
+	i, ok := lit.value.(int)
+	if !ok {
+		return newErrorExpression(compileErrorf(exp.Index.Pos(), TypeMismatch, "incorrect index type. expected int, found %s", lit.typ))
+	}
+	if i < 0 || i >= len(resultTypes) {
+		return newErrorExpression(compileErrorf(exp.Index.Pos(), IndexOutOfRange, "tuple index out of range: %d with %d results", i, len(resultTypes)))
+	}
+	return &tupleIndexCompiledExpression{nopExpression{exp}, exp, xexp, i, resultTypes[i]}
+}
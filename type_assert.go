@@ -2,7 +2,6 @@ package goel
 
 import (
 	"context"
-	"github.com/pkg/errors"
 	"go/ast"
 	"reflect"
 )
@@ -42,17 +41,24 @@ func (tace *typeAssertionCompiledExpression) ReturnType() (reflect.Type, error)
 	return tace.assertType, nil
 }
 
-func (tace *typeAssertionCompiledExpression) Execute(executionContext context.Context) (interface{}, error) {
+func (tace *typeAssertionCompiledExpression) Execute(executionContext context.Context) (result interface{}, err error) {
+	defer recoverRuntimePanic(tace.exp.Lparen, &err)
+	if err := checkpoint(executionContext, tace.exp.Lparen); err != nil {
+		return nil, err
+	}
 	x, err := tace.xexp.Execute(executionContext)
 	if err != nil {
 		return nil, err
 	}
+	if x == nil {
+		return nil, runtimeErrorf(tace.exp.Type.Pos(), TypeMismatch, "nil is not assignable to %s.", tace.assertType.Name())
+	}
 	xvalue := reflect.ValueOf(x)
 	xtyp := xvalue.Type()
 	if xtyp.AssignableTo(tace.assertType) {
 		return xvalue.Convert(tace.assertType).Interface(), nil
 	}
-	return nil, errors.Errorf("%d: %s is not assignable to %s.", tace.exp.Type.Pos(), xtyp.Name(), tace.assertType.Name())
+	return nil, runtimeErrorf(tace.exp.Type.Pos(), TypeMismatch, "%s is not assignable to %s.", xtyp.Name(), tace.assertType.Name())
 }
 
 func evalTypeAssertionExpr(pctx context.Context, exp *ast.TypeAssertExpr) compiledExpression {
@@ -63,16 +69,16 @@ func evalTypeAssertionExpr(pctx context.Context, exp *ast.TypeAssertExpr) compil
 	if ident, ok := exp.Type.(*ast.Ident); ok {
 		assertType, ok := builtinTypeIdentifiers[ident.Name]
 		if !ok {
-			_assertType := pctx.Value(ident.Name)
+			_assertType := lookupDeclaration(pctx, ident.Name)
 			if _assertType == nil {
-				return newErrorExpression(errors.Errorf("%d: unknown type %s", ident.NamePos, ident.Name))
+				return newErrorExpression(compileErrorf(ident.NamePos, UndefinedIdentifier, "unknown type %s", ident.Name))
 			}
 			assertType, ok = _assertType.(reflect.Type)
 			if !ok {
-				return newErrorExpression(errors.Errorf("%d: expected a reflect.Type in the parsing context for %s but found %T", ident.NamePos, ident.Name, _assertType))
+				return newErrorExpression(compileErrorf(ident.NamePos, TypeMismatch, "expected a reflect.Type in the parsing context for %s but found %T", ident.Name, _assertType))
 			}
 		}
-		return &typeAssertionCompiledExpression{nopExpression{}, exp, xexp, assertType}
+		return &typeAssertionCompiledExpression{nopExpression{exp}, exp, xexp, assertType}
 	}
-	return newErrorExpression(errors.Errorf("%d: expression not supported for type assertion: %s", exp.Type.Pos(), exp.Type))
+	return newErrorExpression(compileErrorf(exp.Type.Pos(), UnsupportedExpression, "expression not supported for type assertion: %s", exp.Type))
 }
@@ -0,0 +1,111 @@
+package goel
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Expression is a compiled expression whose result has the type T.  It is created by CompileAs.
+type Expression[T any] struct {
+	CompiledExpression
+	// convertTo is the type the result is converted to when it is a number of another type.
+	convertTo reflect.Type
+}
+
+// CompileAs compiles src like Compile and verifies that the type of its result is assignable to T so that Eval can
+// return it as a T.  With the ConvertNumericResult option, a numeric result of another type is converted to T if T is
+// numeric as well.  The compile errors, including a mismatched result type, are returned as the error.
+func CompileAs[T any](parseContext context.Context, src string, opts ...CompileOption) (*Expression[T], error) {
+	cexp := Compile(parseContext, src, opts...)
+	if err := cexp.Error(); err != nil {
+		return nil, err
+	}
+	target := reflect.TypeOf((*T)(nil)).Elem()
+	typ, _ := cexp.ReturnType()
+	e := &Expression[T]{CompiledExpression: cexp}
+	switch {
+	case assignableResult(typ, target):
+	case typ != nil && compileOptionsFrom(withCompileOptions(parseContext, opts)).convertNumeric && isNumeric(typ) && isNumeric(target) && typ.ConvertibleTo(target):
+		e.convertTo = target
+	default:
+		compiled := cexp.(*expression)
+		err := resultTypeError(compiled.root, typ, target)
+		resolvePositions(err, compiled.fset, compiled.src)
+		return nil, err
+	}
+	return e, nil
+}
+
+// Eval executes the expression with the given execution context and returns its result as a T.
+func (e *Expression[T]) Eval(executionContext context.Context) (T, error) {
+	var result T
+	v, err := e.Execute(executionContext)
+	if err != nil || v == nil {
+		return result, err
+	}
+	if e.convertTo != nil {
+		v = reflect.ValueOf(v).Convert(e.convertTo).Interface()
+	}
+	return v.(T), nil
+}
+
+// wrappedExpression is implemented by the expressions wrapping a CompiledExpression, like Expression.
+type wrappedExpression interface {
+	unwrap() CompiledExpression
+}
+
+func (e *Expression[T]) unwrap() CompiledExpression {
+	return e.CompiledExpression
+}
+
+// ConvertNumericResult lets CompileAs convert a numeric result to a numeric type it is not assignable to, e.g. an int
+// to a float64.  The conversion follows the rules of go and may lose precision.
+func ConvertNumericResult() CompileOption {
+	return func(opts *compileOptions) {
+		opts.convertNumeric = true
+	}
+}
+
+// assignableResult reports whether a result of type typ, which is nil for the nil literal, is assignable to target.
+func assignableResult(typ, target reflect.Type) bool {
+	if typ == nil {
+		return isNillable(target)
+	}
+	return typ.AssignableTo(target)
+}
+
+// ExpectType makes the compilation fail unless the result of the expression is assignable to typ, e.g. to verify
+// that a stored rule is a predicate when typ is BoolType.
+func ExpectType(typ reflect.Type) CompileOption {
+	return func(opts *compileOptions) {
+		opts.expectType = typ
+	}
+}
+
+// checkResultType verifies that the result of the compiled root of an expression is assignable to the expected type
+// if one is set.
+func checkResultType(options *compileOptions, root compiledExpression) error {
+	if options.expectType == nil || root.Error() != nil {
+		return nil
+	}
+	if typ, _ := root.ReturnType(); !assignableResult(typ, options.expectType) {
+		return resultTypeError(root, typ, options.expectType)
+	}
+	return nil
+}
+
+// resultTypeError returns the error reported at root when its result of type typ is not assignable to target.  When
+// target is bool, the error suggests how a string or numeric result may be turned into a predicate.
+func resultTypeError(root compiledExpression, typ, target reflect.Type) error {
+	hint := ""
+	if target == BoolType && typ != nil {
+		switch {
+		case typ.Kind() == reflect.String:
+			hint = fmt.Sprintf("; did you mean %s == \"...\"?", root.Source())
+		case isNumeric(typ):
+			hint = fmt.Sprintf("; did you mean %s != 0?", root.Source())
+		}
+	}
+	return compileErrorf(root.Pos(), TypeMismatch, "expression of type %v is not assignable to %s%s", typ, target, hint)
+}
@@ -2,7 +2,6 @@ package goel
 
 import (
 	"context"
-	"github.com/pkg/errors"
 	"go/ast"
 	"go/token"
 	"reflect"
@@ -20,15 +19,19 @@ func (uce *unaryCompiledExpression) ReturnType() (reflect.Type, error) {
 	return uce.xtyp, nil
 }
 
-func (uce *unaryCompiledExpression) Execute(ectx context.Context) (interface{}, error) {
+func (uce *unaryCompiledExpression) Execute(ectx context.Context) (result interface{}, err error) {
+	defer recoverRuntimePanic(uce.exp.OpPos, &err)
+	if err := checkpoint(ectx, uce.exp.OpPos); err != nil {
+		return nil, err
+	}
 	expValue, err := uce.xexp.Execute(ectx)
 	if err != nil {
 		return nil, err
 	}
-	if reflect.TypeOf(expValue).AssignableTo(uce.xtyp) {
+	if expValue != nil && reflect.TypeOf(expValue).AssignableTo(uce.xtyp) {
 		return uce.operator(expValue), nil
 	}
-	return nil, errors.Errorf("%d: type mismatch.  expected %s, found %T", uce.exp.Pos(), uce.xtyp.Name(), expValue)
+	return nil, runtimeErrorf(uce.exp.Pos(), TypeMismatch, "type mismatch.  expected %s, found %T", uce.xtyp.Name(), expValue)
 }
 
 func negateBool(v interface{}) interface{} {
@@ -58,27 +61,30 @@ func evalUnaryExpr(pctx context.Context, exp *ast.UnaryExpr) compiledExpression
 	}
 	expTyp, err := xexp.ReturnType()
 	if err != nil {
-		return newErrorExpression(errors.Errorf("unexpected return type: %v", err))
+		return newErrorExpression(compileErrorf(exp.OpPos, UnknownErrorKind, "unexpected return type: %v", err))
 	}
 	switch {
 	case expTyp.AssignableTo(BoolType):
 		if exp.Op == token.NOT {
-			return &unaryCompiledExpression{nopExpression{}, exp, xexp, expTyp, negateBool}
+			return &unaryCompiledExpression{nopExpression{exp}, exp, xexp, expTyp, negateBool}
 		}
 	case expTyp.AssignableTo(IntType):
 		switch exp.Op {
 		case token.SUB:
-			return &unaryCompiledExpression{nopExpression{}, exp, xexp, expTyp, negateInt}
+			if compileOptionsFrom(pctx).checkedArithmetic {
+				return &unaryCompiledExpression{nopExpression{exp}, exp, xexp, expTyp, negateintchecked}
+			}
+			return &unaryCompiledExpression{nopExpression{exp}, exp, xexp, expTyp, negateInt}
 		case token.ADD:
-			return &unaryCompiledExpression{nopExpression{}, exp, xexp, expTyp, plusInt}
+			return &unaryCompiledExpression{nopExpression{exp}, exp, xexp, expTyp, plusInt}
 		}
 	case expTyp.AssignableTo(DoubleType):
 		switch exp.Op {
 		case token.SUB:
-			return &unaryCompiledExpression{nopExpression{}, exp, xexp, expTyp, negateFloat}
+			return &unaryCompiledExpression{nopExpression{exp}, exp, xexp, expTyp, negateFloat}
 		case token.ADD:
-			return &unaryCompiledExpression{nopExpression{}, exp, xexp, expTyp, plusFloat}
+			return &unaryCompiledExpression{nopExpression{exp}, exp, xexp, expTyp, plusFloat}
 		}
 	}
-	return newErrorExpression(errors.Errorf("%d: unsupported unary expression: %s%s", exp.OpPos, exp.Op.String(), expTyp.Name()))
+	return newErrorExpression(compileErrorf(exp.OpPos, UnsupportedExpression, "unsupported unary expression: %s%s", exp.Op.String(), expTyp.Name()))
 }